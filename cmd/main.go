@@ -2,54 +2,94 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"iscrie/config"
-	"iscrie/core/importer/maven2"
-	"iscrie/core/importer/raw"
+	"iscrie/core/importer"
+	_ "iscrie/core/importer/formats" // Registers every importer format
 	"iscrie/network"
+	"iscrie/network/middleware"
+	"iscrie/scripts"
 	"iscrie/utils" // ✅ Import du nouveau logger
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 func main() {
+	// Fatal errors, written by the standard logger, are masked like the log
+	log.SetOutput(utils.NewRedactingWriter(os.Stderr))
+
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:]))
+	}
+
 	// Load configuration
-	cfg := initializeConfig()
+	cfg, options := initializeConfig()
 
 	// Validate repository type
 	if !config.IsValidRepositoryType(cfg.Nexus.RepositoryType) {
-		utils.LogError("Invalid repository type: %s. Supported types are: %v", cfg.Nexus.RepositoryType, config.SupportedRepositoryTypes)
+		utils.LogError("Invalid repository type: %s. Supported types are: %v", cfg.Nexus.RepositoryType, config.SupportedRepositoryTypes())
 		os.Exit(1) // Stop the program
 	}
 
 	// Initialize logging system
 	err := utils.InitLogger(cfg.General.LogPath, cfg.General.LogLevel)
 	if err != nil {
-		fmt.Printf("Failed to initialize logger: %v\n", err)
+		fmt.Printf("Failed to initialize logger: %s\n", utils.Redact(err.Error()))
 		os.Exit(1)
 	}
 	defer utils.CloseLogger() // Ensure log file is closed on exit
 
 	utils.LogInfo("Starting Iscrie...")
 
-	// Init HTTP Client and repository verification
+	// Stop dispatching files on SIGINT/SIGTERM, and abort the uploads in flight after the grace period
+	run := trapSignals(options.gracePeriod)
+	defer run.stop()
+
+	// Init HTTP Client and router initialization
 	httpClient := initializeHTTPClient(cfg)
-	verifyRepository(cfg, httpClient)
+	router := initializeRouter(cfg, httpClient)
 
-	// Importers initialization
-	rawImporter, maven2Importer := initializeImporters(cfg, httpClient)
+	// Verify every routed repository exists
+	for _, repository := range routedRepositories(router) {
+		verifyRepository(run.uploads, cfg, httpClient, repository)
+	}
 
-	processFiles(cfg, rawImporter, maven2Importer)
+	filter := initializeFilter(cfg)
+
+	if interrupted := processFiles(run, cfg, router, filter, httpClient, options.interruptReport); interrupted {
+		utils.LogInfo("Processing interrupted. The files to upload again are listed in %s.", options.interruptReport)
+		run.stop()
+		utils.CloseLogger()
+		os.Exit(130)
+	}
 
 	utils.LogInfo("Processing completed. Check logs for details.")
 }
 
+// runOptions holds the command line options that are not part of the configuration.
+type runOptions struct {
+	gracePeriod     time.Duration // Time given to uploads in flight to finish after a signal
+	interruptReport string        // File listing the failed and pending files of an interrupted run
+}
+
 // initializeConfig loads configuration from TOML file and processes flags.
-func initializeConfig() *config.Config {
+func initializeConfig() (*config.Config, runOptions) {
 	configPath := flag.String("config", "iscrie.toml", "Path to the configuration file")
+	dryRun := flag.Bool("dry-run", false, "Log the target of every file without uploading it")
+	archivePath := flag.String("archive", "", "Upload the entries of a zip or tar archive instead of root_path")
+	gracePeriod := flag.Duration("grace-period", 30*time.Second, "Time given to uploads in flight to finish after SIGINT or SIGTERM")
+	interruptReport := flag.String("interrupt-report", "iscrie-interrupted.json", "Path of the JSON report written when the run is interrupted")
 	flag.Parse()
 
 	utils.LogInfo("Loading configuration from: %s", *configPath)
@@ -57,23 +97,194 @@ func initializeConfig() *config.Config {
 	if err != nil {
 		utils.LogError("Error loading configuration: %v", err)
 	}
+	if *dryRun {
+		cfg.General.DryRun = true
+	}
+	applyArchivePath(cfg, *archivePath)
+
+	return cfg, runOptions{gracePeriod: *gracePeriod, interruptReport: *interruptReport}
+}
+
+// shutdown tracks the interruption of a run: no file is dispatched once a signal is received,
+// and the uploads in flight are aborted when the grace period elapses or on a second signal.
+type shutdown struct {
+	dispatch context.Context // Done once no new file should be uploaded
+	uploads  context.Context // Done once the uploads in flight must be aborted
+	stop     func()          // Releases the signal handler
+}
+
+// trapSignals installs the SIGINT and SIGTERM handler of a run.
+func trapSignals(gracePeriod time.Duration) *shutdown {
+	dispatch, stopDispatch := context.WithCancel(context.Background())
+	uploads, abortUploads := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			utils.LogInfo("Received %s: no new file will be uploaded, waiting up to %s for uploads in flight (send it again to abort them)", sig, gracePeriod)
+			stopDispatch()
+		case <-done:
+			return
+		}
+		select {
+		case sig := <-signals:
+			utils.LogInfo("Received %s again: aborting uploads in flight", sig)
+		case <-time.After(gracePeriod):
+			utils.LogInfo("Grace period elapsed: aborting uploads in flight")
+		case <-done:
+			return
+		}
+		abortUploads()
+	}()
+
+	var once sync.Once
+	return &shutdown{
+		dispatch: dispatch,
+		uploads:  uploads,
+		stop: func() {
+			once.Do(func() {
+				signal.Stop(signals)
+				close(done)
+				stopDispatch()
+				abortUploads()
+			})
+		},
+	}
+}
+
+// applyArchivePath replaces root_path with the archive given on the command line, if any.
+func applyArchivePath(cfg *config.Config, archivePath string) {
+	if archivePath == "" {
+		return
+	}
+	paths, err := utils.ConvertPathsToAbsolute(archivePath)
+	if err != nil {
+		log.Fatalf("Invalid archive path: %v", err)
+	}
+	cfg.General.RootPath = paths[0]
+}
+
+// runVerify implements `iscrie verify`: it checks that the files of root_path are in Nexus
+// with the same content and writes a JSON report. It returns the exit code.
+func runVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	configPath := flags.String("config", "iscrie.toml", "Path to the configuration file")
+	archivePath := flags.String("archive", "", "Verify the entries of a zip or tar archive instead of root_path")
+	reportPath := flags.String("report", "iscrie-verify.json", "Path of the JSON report, - for stdout")
+	failOnExtra := flags.Bool("fail-on-extra", false, "Also fail if the repositories hold assets matching no file")
+	runDate := flags.String("date", "", "Date of the upload run, YYYY-MM-DD or YYYYMMDDTHHMMSS, for the ${date} and ${datetime} of raw.rewrite (default: now)")
+	flags.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Error loading configuration: %s\n", utils.Redact(err.Error()))
+		return 2
+	}
+	applyArchivePath(cfg, *archivePath)
+	if *runDate != "" {
+		if cfg.General.RunTime, err = parseRunDate(*runDate); err != nil {
+			fmt.Printf("Invalid --date: %v\n", err)
+			return 2
+		}
+	}
+
+	if err := utils.InitLogger(cfg.General.LogPath, cfg.General.LogLevel); err != nil {
+		fmt.Printf("Failed to initialize logger: %s\n", utils.Redact(err.Error()))
+		return 2
+	}
+	defer utils.CloseLogger()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpClient := initializeHTTPClient(cfg)
+	report, err := scripts.ValidateNexusUpload(ctx, cfg, httpClient)
+	if err != nil {
+		utils.LogError("Verification failed: %v", err)
+		return 2
+	}
+	report.LogSummary()
+
+	output := os.Stdout
+	if *reportPath != "-" {
+		if output, err = os.Create(*reportPath); err != nil {
+			utils.LogError("Failed to create report: %v", err)
+			return 2
+		}
+		defer output.Close()
+	}
+	if err := report.WriteJSON(output); err != nil {
+		utils.LogError("Failed to write report: %v", err)
+		return 2
+	}
+	if *reportPath != "-" {
+		utils.LogInfo("Verification report written to %s", *reportPath)
+	}
 
-	return cfg
+	if report.Failed() || (*failOnExtra && len(report.Extra) > 0) {
+		return 1
+	}
+	return 0
+}
+
+// parseRunDate reads the date of an upload run, as expanded by the ${datetime} or ${date} placeholders.
+func parseRunDate(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405", "2006-01-02"} {
+		if runTime, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return runTime, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("'%s' is neither YYYY-MM-DD nor YYYYMMDDTHHMMSS", value)
 }
 
 // initializeHTTPClient configures HTTP client with authentication and proxy.
 func initializeHTTPClient(cfg *config.Config) *network.HTTPClient {
-	httpClient, err := network.NewHTTPClient(cfg.Auth, cfg.Proxy)
+	httpClient, err := network.NewHTTPClient(cfg.Auth, cfg.Proxy, cfg.TLS)
 	if err != nil {
 		log.Fatalf("Failed to initialize HTTP client: %v", err)
 	}
+	httpClient.DryRun = cfg.General.DryRun
+	httpClient.VerifyChecksums = cfg.Nexus.VerifyChecksums
+	httpClient.ContentTypes = cfg.ContentTypeMap()
+	httpClient.Retry = middleware.Policy{
+		Attempts:   cfg.Retry.RetryAttempts,
+		Backoff:    time.Duration(cfg.Retry.Backoff) * time.Second,
+		MaxBackoff: time.Duration(cfg.Retry.MaxBackoff) * time.Second,
+	}
+	httpClient.SetTimeout(time.Duration(cfg.Retry.Timeout) * time.Second)
+	httpClient.Limiter, err = network.NewRateLimiter(cfg.Network)
+	if err != nil {
+		log.Fatalf("Failed to initialize rate limits: %v", err)
+	}
+	httpClient.Concurrency = newConcurrency(cfg)
+	httpClient.Progress = network.NewProgress(cfg.General.Progress, time.Duration(cfg.General.ProgressInterval)*time.Second)
+	if httpClient.DryRun {
+		utils.LogInfo("Dry run: no file will be uploaded.")
+	}
 	return httpClient
 }
 
+// newConcurrency builds the controller of the upload worker pool: general.batch_size workers, or an
+// adaptive number of workers starting at general.batch_size.
+func newConcurrency(cfg *config.Config) *middleware.Concurrency {
+	if cfg.Concurrency.Mode != config.ConcurrencyAdaptive {
+		utils.LogDebug("Uploading up to %d files in parallel", cfg.General.BatchSize)
+		return middleware.NewFixedConcurrency(cfg.General.BatchSize)
+	}
+	concurrency := middleware.NewAdaptiveConcurrency(cfg.General.BatchSize, cfg.Concurrency.Min, cfg.Concurrency.Max,
+		cfg.Concurrency.LatencyFactor, cfg.Concurrency.DecreaseFactor)
+	utils.LogInfo("Adaptive concurrency: starting with %d parallel uploads, between %d and %d",
+		concurrency.Limit(), cfg.Concurrency.Min, cfg.Concurrency.Max)
+	return concurrency
+}
+
 // Verify if repository exists in Nexus
-func verifyRepository(cfg *config.Config, httpClient *network.HTTPClient) {
+func verifyRepository(ctx context.Context, cfg *config.Config, httpClient *network.HTTPClient, repository string) {
 
-	httpClientAdapter := network.NewHTTPClientAdapter(httpClient, cfg.Nexus.URL, cfg.Nexus.Repository, false)
+	httpClientAdapter := network.NewHTTPClientAdapter(httpClient, cfg.Nexus.URL, repository, false)
 
 	// Create Nexus Client
 	nexusClient, err := network.NewNexusClient(cfg.Nexus.URL, httpClientAdapter)
@@ -81,83 +292,419 @@ func verifyRepository(cfg *config.Config, httpClient *network.HTTPClient) {
 		utils.LogError("Failed to create Nexus client: %v", err)
 	}
 
-	exists, err := nexusClient.RepositoryExists(cfg.Nexus.Repository)
+	exists, err := nexusClient.RepositoryExists(ctx, repository)
 	if err != nil {
 		utils.LogError("Failed to check repository existence: %v", err)
 	}
 	if !exists {
-		utils.LogError("Repository '%s' does not exist in Nexus", cfg.Nexus.Repository)
+		utils.LogError("Repository '%s' does not exist in Nexus", repository)
 	}
-	utils.LogDebug("Verified repository '%s' exists in Nexus.", cfg.Nexus.Repository)
+	utils.LogDebug("Verified repository '%s' exists in Nexus.", repository)
 }
 
-// initializeImporters init RAW and Maven2 importers.
-func initializeImporters(cfg *config.Config, httpClient *network.HTTPClient) (*raw.RawImporter, *maven2.Maven2Importer) {
-	rawImporter := raw.NewRawImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace)
-	maven2Importer := maven2.NewMaven2Importer(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace)
-	return rawImporter, maven2Importer
+// initializeRouter builds the uploaders of the configured routes and of the default route.
+func initializeRouter(cfg *config.Config, httpClient *network.HTTPClient) *importer.Router {
+	router, err := importer.NewRouter(cfg, httpClient)
+	if err != nil {
+		log.Fatalf("Failed to initialize routes: %v", err)
+	}
+	for _, route := range router.Routes {
+		utils.LogDebug("Route '%s': %s -> %s (%s)", route.Name, route.Pattern, route.Repository, route.Format.RepositoryType)
+	}
+	return router
 }
 
-// processFiles walk through files and processes them according to their type.
-func processFiles(cfg *config.Config, rawImporter *raw.RawImporter, maven2Importer *maven2.Maven2Importer) {
-	utils.LogDebug("Walking through files in: %s", cfg.General.RootPath)
+// initializeFilter builds the include/exclude and size/date filters of the walk.
+func initializeFilter(cfg *config.Config) *importer.Filter {
+	filter, err := importer.NewFilter(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize file filters: %v", err)
+	}
+	return filter
+}
+
+// routedRepositories lists the distinct repositories targeted by the routes, in route order.
+func routedRepositories(router *importer.Router) []string {
+	seen := map[string]bool{}
+	var repositories []string
+	for _, route := range router.Routes {
+		if !seen[route.Repository] {
+			seen[route.Repository] = true
+			repositories = append(repositories, route.Repository)
+		}
+	}
+	return repositories
+}
+
+// routeSummary counts the outcome of the files sent to a route.
+type routeSummary struct {
+	total, successful, failed, skipped int
+	unhandled                          int // Files matching the route that its importer rejects
+}
+
+// processingStats accumulates the outcome of the walk for the final summary.
+// It is shared by the walk and the upload workers, which hold mu while updating it.
+type processingStats struct {
+	mu                                                         sync.Mutex
+	totalFiles, successfulUploads, failedUploads, skippedFiles int
+	quarantinedFiles                                           []error
+	filteredFiles                                              map[string]int
+	excludedDirs                                               int
+	unhandledFiles                                             int // Files rejected by the importer of their route
+	routes                                                     map[*importer.Route]*routeSummary
+	uploadErrors                                               []error
+	failedFiles                                                []fileFailure // Files whose upload failed
+	pendingFiles                                               []string      // Files not uploaded because the run was interrupted
+}
 
+// fileFailure is a failed file of the interrupt report.
+type fileFailure struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// uploadPool runs the uploads of the walk in background workers, as many as the concurrency allows.
+type uploadPool struct {
+	concurrency *middleware.Concurrency
+	progress    *network.Progress // Nil if progress reporting is off
+	workers     sync.WaitGroup
+}
+
+// start waits for a free worker, then runs the upload in it. It returns the error of ctx if it is done first.
+func (p *uploadPool) start(ctx context.Context, upload func()) error {
+	if err := p.concurrency.Acquire(ctx); err != nil {
+		return err
+	}
+	p.workers.Add(1)
+	go func() {
+		defer p.workers.Done()
+		defer p.concurrency.Release()
+		upload()
+	}()
+	return nil
+}
+
+// wait blocks until every started upload is done.
+func (p *uploadPool) wait() {
+	p.workers.Wait()
+}
+
+// processFiles walk through files, or the entries of an archive root, and processes them according to their route.
+// Once the run is interrupted, the remaining files are listed as pending instead of being uploaded, and the
+// failed and pending files are written to interruptReport. It reports whether the run was interrupted.
+func processFiles(run *shutdown, cfg *config.Config, router *importer.Router, filter *importer.Filter,
+	httpClient *network.HTTPClient, interruptReport string) bool {
 	start := time.Now()
-	totalFiles, successfulUploads, failedUploads := 0, 0, 0
-	var uploadErrors []error
+	stats := &processingStats{
+		filteredFiles: make(map[string]int, len(importer.SkipReasons)),
+		routes:        make(map[*importer.Route]*routeSummary, len(router.Routes)),
+	}
+	for _, route := range router.Routes {
+		stats.routes[route] = &routeSummary{}
+	}
+
+	pool := &uploadPool{concurrency: httpClient.Concurrency, progress: httpClient.Progress}
+	var err error
+	if importer.IsArchive(cfg.General.RootPath) {
+		pool.progress.Start() // The entries are not counted beforehand, so there is no ETA
+		err = walkArchive(run, pool, cfg, router, filter, stats)
+	} else {
+		if pool.progress != nil {
+			pool.progress.SetTotal(countFiles(cfg, router, filter))
+		}
+		pool.progress.Start()
+		err = walkDirectory(run, pool, cfg, router, filter, stats)
+	}
+	pool.wait()
+	pool.progress.Stop()
+	if err != nil {
+		utils.LogError("Error during file traversal: %v", err)
+	}
+
+	reportSummary(router, stats, httpClient.Attempts, httpClient.Concurrency, time.Since(start))
+	if run.dispatch.Err() == nil {
+		return false
+	}
+	if err := writeInterruptReport(interruptReport, stats); err != nil {
+		utils.LogError("Failed to write interrupt report: %v", err)
+	}
+	return true
+}
 
-	err := filepath.WalkDir(cfg.General.RootPath, func(path string, d os.DirEntry, err error) error {
+// writeInterruptReport writes the partial summary of an interrupted run with its failed and pending files.
+func writeInterruptReport(reportPath string, stats *processingStats) error {
+	report := struct {
+		Summary map[string]int `json:"summary"`
+		Failed  []fileFailure  `json:"failed"`
+		Pending []string       `json:"pending"`
+	}{
+		Summary: map[string]int{
+			"processed":   stats.totalFiles,
+			"successful":  stats.successfulUploads,
+			"failed":      stats.failedUploads,
+			"skipped":     stats.skippedFiles,
+			"unhandled":   stats.unhandledFiles,
+			"quarantined": len(stats.quarantinedFiles),
+			"pending":     len(stats.pendingFiles),
+		},
+		Failed:  append([]fileFailure{}, stats.failedFiles...),
+		Pending: append([]string{}, stats.pendingFiles...),
+	}
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(reportPath, append(content, '\n'), 0o644)
+}
+
+// walkDirectory processes the files under root_path.
+func walkDirectory(run *shutdown, pool *uploadPool, cfg *config.Config, router *importer.Router, filter *importer.Filter, stats *processingStats) error {
+	utils.LogDebug("Walking through files in: %s", cfg.General.RootPath)
+
+	return filepath.WalkDir(cfg.General.RootPath, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			utils.LogError("Error accessing path: %v", err)
-			uploadErrors = append(uploadErrors, fmt.Errorf("error accessing path %s: %w", path, err))
+			stats.mu.Lock()
+			stats.uploadErrors = append(stats.uploadErrors, fmt.Errorf("error accessing path %s: %w", path, err))
+			stats.mu.Unlock()
 			return nil
 		}
-		if !d.IsDir() {
-			totalFiles++
-			utils.LogInfo("Processing file: %s", path)
-
-			var uploadErr error
-			if cfg.Nexus.RepositoryType == "maven2" {
-				utils.LogInfo("Detected Maven2 file: %s", path)
-				uploadErr = maven2Importer.UploadMaven2File(path, cfg.Retry.RetryAttempts, utils.LogDebug, utils.LogError)
-			} else if cfg.Nexus.RepositoryType == "raw" {
-				utils.LogInfo("Detected RAW file: %s", path)
-				uploadErr = rawImporter.UploadRawFile(path, cfg.Retry.RetryAttempts, utils.LogDebug, utils.LogError)
-			} else {
-				utils.LogInfo("Unsupported repository type: %s", cfg.Nexus.RepositoryType)
+		if d.IsDir() {
+			if filter.SkipDir(path) {
+				stats.excludedDirs++ // Only updated by the walk
+				utils.LogDebug("Skipping excluded directory: %s", path)
+				return filepath.SkipDir
 			}
+			return nil
+		}
 
-			if uploadErr != nil {
-				failedUploads++
-				uploadErrors = append(uploadErrors, fmt.Errorf("failed to upload file %s: %w", path, uploadErr))
-				utils.LogError("Error uploading file: %s, error: %v", path, uploadErr)
-			} else {
-				successfulUploads++
-				utils.LogDebug("Successfully uploaded file: %s", path)
-			}
+		info, infoErr := d.Info()
+		if infoErr != nil {
+			utils.LogError("Error reading file info: %v", infoErr)
+			stats.mu.Lock()
+			stats.uploadErrors = append(stats.uploadErrors, fmt.Errorf("error reading file info %s: %w", path, infoErr))
+			stats.mu.Unlock()
+			return nil
+		}
+		processFile(run, pool, router, filter, stats, path, info, func(ctx context.Context, route *importer.Route) error {
+			return route.Upload(ctx, path, cfg.Retry.RetryAttempts, utils.LogDebug, utils.LogError)
+		})
+		return nil
+	})
+}
+
+// walkArchive processes the entries of the archive root, streaming each one into its upload.
+// Entries are read in order, so they are uploaded one at a time.
+func walkArchive(run *shutdown, pool *uploadPool, cfg *config.Config, router *importer.Router, filter *importer.Filter, stats *processingStats) error {
+	utils.LogDebug("Walking through entries of archive: %s", cfg.General.RootPath)
+
+	if router.Verifier != nil {
+		return utils.LogAndReturnError("signature verification is not supported when uploading from an archive")
+	}
+	for _, route := range router.Routes {
+		if route.UploadEntry == nil {
+			return utils.LogAndReturnError("route '%s': repository type '%s' cannot upload from an archive", route.Name, route.Format.RepositoryType)
 		}
+	}
+
+	return importer.WalkArchive(cfg.General.RootPath, func(entry *importer.ArchiveEntry) error {
+		processFile(run, pool, router, filter, stats, entry.Path, entry.Info, func(ctx context.Context, route *importer.Route) error {
+			return route.UploadEntry(ctx, entry, cfg.Retry.RetryAttempts, utils.LogDebug, utils.LogError)
+		})
+		pool.wait() // The entry is only readable until the next one
 		return nil
 	})
+}
+
+// processFile filters and routes a single file or archive entry, then uploads it in a worker of the pool.
+func processFile(run *shutdown, pool *uploadPool, router *importer.Router, filter *importer.Filter, stats *processingStats,
+	path string, info os.FileInfo, upload func(ctx context.Context, route *importer.Route) error) {
+	route, ok := routeFile(run, router, filter, stats, path, info)
+	if !ok {
+		return
+	}
 
+	err := pool.start(run.dispatch, func() {
+		utils.LogInfo("Processing %s file via route '%s': %s", route.Format.RepositoryType, route.Name, path)
+		ctx := pool.progress.StartFile(run.uploads, path, info.Size())
+		uploadErr := upload(ctx, route)
+		pool.progress.FinishFile(path)
+		recordUpload(run, stats, route, path, uploadErr)
+	})
 	if err != nil {
-		utils.LogError("Error during file traversal: %v", err)
+		stats.mu.Lock()
+		stats.pendingFiles = append(stats.pendingFiles, path)
+		stats.mu.Unlock()
 	}
+}
 
-	duration := time.Since(start)
-	utils.LogInfo("Total files processed: %d", totalFiles)
-	utils.LogInfo("Successful uploads: %d", successfulUploads)
-	utils.LogInfo("Failed uploads: %d", failedUploads)
+// countFiles counts the files under root_path that the walk will upload, and their size, for the progress report.
+func countFiles(cfg *config.Config, router *importer.Router, filter *importer.Filter) (int, int64) {
+	files, bytes := 0, int64(0)
+	filepath.WalkDir(cfg.General.RootPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Reported by the walk itself
+		}
+		if d.IsDir() {
+			if filter.SkipDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || filter.Skip(path, info) != "" {
+			return nil
+		}
+		if route, err := router.Route(path); err == nil && route.Format.Matches(path) {
+			files++
+			bytes += info.Size()
+		}
+		return nil
+	})
+	return files, bytes
+}
+
+// routeFile returns the route of a file, or false if the file is filtered out, unrouted, not handled
+// by the importer of its route or pending because the run was interrupted.
+func routeFile(run *shutdown, router *importer.Router, filter *importer.Filter, stats *processingStats,
+	path string, info os.FileInfo) (*importer.Route, bool) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	if reason := filter.Skip(path, info); reason != "" {
+		stats.filteredFiles[reason]++
+		utils.LogDebug("Skipping filtered file (%s): %s", reason, path)
+		return nil, false
+	}
+
+	route, routeErr := router.Route(path)
+	if routeErr != nil {
+		utils.LogError("Error routing file %s: %v", path, routeErr)
+		stats.uploadErrors = append(stats.uploadErrors, fmt.Errorf("error routing file %s: %w", path, routeErr))
+		return nil, false
+	}
+	summary := stats.routes[route]
+
+	if !route.Format.Matches(path) {
+		stats.unhandledFiles++
+		summary.unhandled++
+		utils.LogInfo("Skipping file not handled by the %s importer of route '%s': %s", route.Format.RepositoryType, route.Name, path)
+		return nil, false
+	}
+
+	if run.dispatch.Err() != nil {
+		stats.pendingFiles = append(stats.pendingFiles, path)
+		return nil, false
+	}
+	return route, true
+}
+
+// recordUpload counts the outcome of the upload of a file.
+func recordUpload(run *shutdown, stats *processingStats, route *importer.Route, path string, uploadErr error) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	if uploadErr != nil && run.uploads.Err() != nil {
+		stats.pendingFiles = append(stats.pendingFiles, path)
+		utils.LogError("Upload aborted: %s", path)
+		return
+	}
+
+	summary := stats.routes[route]
+	stats.totalFiles++
+	summary.total++
+	if errors.Is(uploadErr, network.ErrAssetExists) {
+		stats.skippedFiles++
+		summary.skipped++
+		utils.LogInfo("Skipping file already in Nexus (force_replace is disabled): %s", path)
+	} else if importer.IsQuarantined(uploadErr) {
+		stats.quarantinedFiles = append(stats.quarantinedFiles, uploadErr)
+		summary.skipped++
+	} else if uploadErr != nil {
+		stats.failedUploads++
+		summary.failed++
+		stats.uploadErrors = append(stats.uploadErrors, fmt.Errorf("failed to upload file %s: %w", path, uploadErr))
+		stats.failedFiles = append(stats.failedFiles, fileFailure{File: path, Error: uploadErr.Error()})
+		utils.LogError("Error uploading file: %s, error: %v", path, uploadErr)
+	} else {
+		stats.successfulUploads++
+		summary.successful++
+		utils.LogDebug("Successfully uploaded file: %s", path)
+	}
+}
+
+// reportSummary logs the totals, the filter and per-route counts and the upload errors.
+func reportSummary(router *importer.Router, stats *processingStats, attempts *middleware.AttemptLog,
+	concurrency *middleware.Concurrency, duration time.Duration) {
+	utils.LogInfo("Total files processed: %d", stats.totalFiles)
+	utils.LogInfo("Successful uploads: %d", stats.successfulUploads)
+	utils.LogInfo("Failed uploads: %d", stats.failedUploads)
+	utils.LogInfo("Skipped files: %d", stats.skippedFiles)
+	for _, reason := range importer.SkipReasons {
+		if stats.filteredFiles[reason] > 0 {
+			utils.LogInfo("Filtered files (%s): %d", reason, stats.filteredFiles[reason])
+		}
+	}
+	if stats.excludedDirs > 0 {
+		utils.LogInfo("Excluded directories: %d", stats.excludedDirs)
+	}
+	if stats.unhandledFiles > 0 {
+		utils.LogInfo("Files not handled by the importer of their route: %d", stats.unhandledFiles)
+	}
+	if len(stats.pendingFiles) > 0 {
+		utils.LogInfo("Pending files (run interrupted): %d", len(stats.pendingFiles))
+	}
+	if len(stats.quarantinedFiles) > 0 {
+		utils.LogInfo("Quarantined files (signature verification): %d", len(stats.quarantinedFiles))
+		for _, quarantineErr := range stats.quarantinedFiles {
+			utils.LogInfo("  %v", quarantineErr)
+		}
+	}
+	if retried := attempts.Files(); len(retried) > 0 {
+		utils.LogInfo("Files with failed attempts: %d", len(retried))
+		for _, filePath := range retried {
+			record, _ := attempts.Get(filePath)
+			outcome := "succeeded"
+			if record.Failed {
+				outcome = "failed"
+			}
+			utils.LogInfo("  %s: %s after %d attempt(s) (%s)", filePath, outcome, record.Attempts, strings.Join(record.Reasons, ", "))
+		}
+	}
+	if concurrency.Adaptive() {
+		summary := concurrency.Summary()
+		utils.LogInfo("Concurrency: started at %d, ended at %d, ranged from %d to %d",
+			summary.Initial, summary.Final, summary.Low, summary.High)
+		if summary.Dropped > 0 {
+			utils.LogInfo("  (%d earlier changes not shown)", summary.Dropped)
+		}
+		for _, change := range summary.Timeline {
+			utils.LogInfo("  %s: %d -> %d (%s)", change.At.Round(time.Second), change.From, change.To, change.Reason)
+		}
+	}
 	utils.LogInfo("Time taken: %s", duration)
 
-	if len(uploadErrors) > 0 {
+	utils.LogInfo("Per-route summary:")
+	routesFailed := false
+	for _, route := range router.Routes {
+		summary := stats.routes[route]
+		routesFailed = routesFailed || summary.failed > 0
+		utils.LogInfo("  %s -> %s (%s): processed %d, successful %d, failed %d, skipped %d, not handled %d",
+			route.Name, route.Repository, route.Format.RepositoryType,
+			summary.total, summary.successful, summary.failed, summary.skipped, summary.unhandled)
+	}
+
+	if len(stats.uploadErrors) > 0 {
 		utils.LogInfo("The following errors occurred during upload:")
 		var errorBuffer bytes.Buffer
-		for _, uploadErr := range uploadErrors {
+		for _, uploadErr := range stats.uploadErrors {
 			utils.LogError("%v", uploadErr)
 			errorBuffer.WriteString(fmt.Sprintf("%v\n", uploadErr))
 		}
 		utils.LogInfo("Upload completed with errors:\n%s", errorBuffer.String())
 	}
 
-	utils.LogInfo("All files uploaded successfully.")
+	if len(stats.pendingFiles) == 0 && len(stats.uploadErrors) == 0 && !routesFailed {
+		utils.LogInfo("All files uploaded successfully.")
+	}
 }
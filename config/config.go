@@ -1,21 +1,35 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"iscrie/utils"
+	"mime"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Default values
 const (
-	DefaultBatchSize     = 1   // Default batch Size
-	MaxBatchSize         = 100 // Max allowed batch_size
-	DefaultRetryTimeout  = 10  // Timeout by default per second
-	DefaultRetryAttempts = 3   // number of retries attempt
+	DefaultBatchSize      = 1         // Default batch Size
+	MaxBatchSize          = 100       // Max allowed batch_size
+	DefaultRetryTimeout   = 30        // Connection and response header timeout by default, in seconds
+	DefaultRetryAttempts  = 3         // number of retries attempt
+	DefaultRetryBackoff   = 2         // Delay before the first retry, in seconds
+	DefaultMaxBackoff     = 60        // Max delay between two attempts, in seconds
+	DefaultRouteName      = "default" // Name of the route built from the [nexus] section
+	DefaultMaxConcurrency = 16        // Highest parallelism of the adaptive mode by default
 )
 
 // AuthConfig defines the authentication configuration
@@ -26,15 +40,178 @@ type AuthConfig struct {
 	AccessToken string `mapstructure:"access_token"`
 	HeaderName  string `mapstructure:"header_name"`
 	HeaderValue string `mapstructure:"header_value"`
+	// OAuth2 fetches the access tokens of auth.type "oauth2" from a token endpoint
+	OAuth2 OAuth2Config `mapstructure:"oauth2"`
 }
 
+// Ways of sending the OAuth2 client credentials to the token endpoint
+var OAuth2AuthStyles = []string{"header", "body"}
+
+// OAuth2Config defines the token endpoint and the grant of the oauth2 authentication. The client
+// credentials grant is used, or the refresh token grant if refresh_token is set.
+type OAuth2Config struct {
+	TokenURL      string   `mapstructure:"token_url"`
+	ClientID      string   `mapstructure:"client_id"`
+	ClientSecret  string   `mapstructure:"client_secret"`
+	RefreshToken  string   `mapstructure:"refresh_token"`
+	Scopes        []string `mapstructure:"scopes"`
+	AuthStyle     string   `mapstructure:"auth_style"`     // header (HTTP Basic) or body (form parameters)
+	RefreshBefore int      `mapstructure:"refresh_before"` // Seconds before expiry at which the token is renewed
+}
+
+// Proxy schemes accepted in proxy.scheme
+var ProxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
 // ProxyConfig defines proxy configuration
 type ProxyConfig struct {
 	Enabled  bool   `mapstructure:"enabled"`
+	Scheme   string `mapstructure:"scheme"` // http, https, socks5 or socks5h (DNS resolved by the proxy)
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
-	Username string `mapstructure:"username"`
+	Username string `mapstructure:"username"` // Basic proxy authentication, or SOCKS5 username
 	Password string `mapstructure:"password"`
+	// HeaderName and HeaderValue authenticate to http and https proxies with a custom header instead
+	HeaderName  string `mapstructure:"header_name"`
+	HeaderValue string `mapstructure:"header_value"`
+	// NoProxy lists the hosts reached directly: "*", IP addresses, CIDR ranges and domain suffixes, with an optional ":port"
+	NoProxy []string `mapstructure:"no_proxy"`
+	// UseEnvironment falls back on HTTP_PROXY, HTTPS_PROXY, ALL_PROXY and NO_PROXY if the proxy is not enabled
+	UseEnvironment bool `mapstructure:"use_environment"`
+}
+
+// Minimum TLS versions accepted in tls.min_version
+var TLSVersions = []string{"1.0", "1.1", "1.2", "1.3"}
+
+// TLSConfig defines the TLS settings of the connections to Nexus and to the proxy
+type TLSConfig struct {
+	CAFiles    []string `mapstructure:"ca_files"`    // PEM bundles trusted in addition to the system roots
+	CertFile   string   `mapstructure:"cert_file"`   // PEM client certificate for mutual TLS
+	KeyFile    string   `mapstructure:"key_file"`    // PEM private key of the client certificate
+	MinVersion string   `mapstructure:"min_version"` // 1.0, 1.1, 1.2 or 1.3
+	ServerName string   `mapstructure:"server_name"` // Name expected in the Nexus certificate, if not the host of nexus.url
+	// PinnedSPKI lists the accepted keys as "sha256/<base64>" hashes of their SubjectPublicKeyInfo
+	PinnedSPKI         []string `mapstructure:"pinned_spki"`
+	InsecureSkipVerify bool     `mapstructure:"insecure_skip_verify"` // Disables certificate verification; never use in production
+}
+
+// RateLimits bounds the upload bandwidth and request rate, globally and per Nexus host. Zero means unlimited.
+type RateLimits struct {
+	BytesPerSecond           int64   `mapstructure:"bytes_per_second"`
+	RequestsPerSecond        float64 `mapstructure:"requests_per_second"`
+	PerHostBytesPerSecond    int64   `mapstructure:"per_host_bytes_per_second"`
+	PerHostRequestsPerSecond float64 `mapstructure:"per_host_requests_per_second"`
+}
+
+// LimitSchedule replaces the limits of [network] between two times of day, local time.
+// The window wraps around midnight when end is before start.
+type LimitSchedule struct {
+	Start      string `mapstructure:"start"` // HH:MM
+	End        string `mapstructure:"end"`   // HH:MM, excluded
+	RateLimits `mapstructure:",squash"`
+}
+
+// NetworkConfig defines the rate limits of uploads
+type NetworkConfig struct {
+	RateLimits `mapstructure:",squash"`
+	Schedules  []LimitSchedule `mapstructure:"schedule"` // The first matching window wins
+}
+
+// Modes of general.progress
+const (
+	ProgressAuto = "auto" // Live display when stdout is a terminal, periodic log lines otherwise
+	ProgressLog  = "log"  // Periodic log lines
+	ProgressOff  = "off"
+)
+
+// ProgressModes lists the accepted values of general.progress
+var ProgressModes = []string{ProgressAuto, ProgressLog, ProgressOff}
+
+// Modes of the upload worker pool
+const (
+	ConcurrencyFixed    = "fixed"    // general.batch_size uploads run in parallel
+	ConcurrencyAdaptive = "adaptive" // Parallelism follows the latency and errors of the server, between min and max
+)
+
+// ConcurrencyModes lists the accepted values of concurrency.mode
+var ConcurrencyModes = []string{ConcurrencyFixed, ConcurrencyAdaptive}
+
+// ConcurrencyConfig defines how many uploads run in parallel. In adaptive mode, general.batch_size is the
+// initial parallelism: it grows by one per round of healthy requests and is multiplied by decrease_factor
+// on 429, 5xx, timeouts or when the latency exceeds latency_factor times the best latency seen.
+type ConcurrencyConfig struct {
+	Mode           string  `mapstructure:"mode"`
+	Min            int     `mapstructure:"min"`
+	Max            int     `mapstructure:"max"`
+	LatencyFactor  float64 `mapstructure:"latency_factor"`
+	DecreaseFactor float64 `mapstructure:"decrease_factor"`
+}
+
+// RewriteRule is one step of the raw target path rewriting. Each rule sets exactly one action.
+type RewriteRule struct {
+	StripComponents int    `mapstructure:"strip_components"`
+	Prefix          string `mapstructure:"prefix"`
+	Regex           string `mapstructure:"regex"`
+	Replace         string `mapstructure:"replace"`
+	Lowercase       bool   `mapstructure:"lowercase"`
+}
+
+// RawConfig defines settings specific to raw repositories
+type RawConfig struct {
+	Rewrite []RewriteRule `mapstructure:"rewrite"`
+}
+
+// AptConfig defines settings specific to apt repositories
+type AptConfig struct {
+	Architectures []string `mapstructure:"architectures"`
+}
+
+// YumConfig defines settings specific to yum repositories
+type YumConfig struct {
+	RepodataDepth    int    `mapstructure:"repodata_depth"`
+	Layout           string `mapstructure:"layout"`
+	RequireSignature bool   `mapstructure:"require_signature"`
+}
+
+// SigningConfig defines the OpenPGP key used to sign uploaded files
+type SigningConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Keyring        string `mapstructure:"keyring"`         // Armored secret keyring file
+	KeyID          string `mapstructure:"key_id"`          // Optional key ID or fingerprint, the first signing key otherwise
+	PassphraseEnv  string `mapstructure:"passphrase_env"`  // Environment variable holding the passphrase
+	PassphraseFile string `mapstructure:"passphrase_file"` // File holding the passphrase
+}
+
+// Actions taken on files whose signature fails verification
+const (
+	OnFailureRefuse     = "refuse"     // The file fails and is reported as an upload error
+	OnFailureQuarantine = "quarantine" // The file is not uploaded and is listed apart in the summary
+)
+
+// VerificationConfig defines how existing .asc signatures are checked before upload
+type VerificationConfig struct {
+	Enabled          bool     `mapstructure:"enabled"`
+	Keyring          string   `mapstructure:"keyring"`           // Armored public keyring
+	TrustedKeys      []string `mapstructure:"trusted_keys"`      // Optional key IDs or fingerprints, every key of the keyring otherwise
+	RequireSignature bool     `mapstructure:"require_signature"` // If true, files without an .asc fail verification
+	OnFailure        string   `mapstructure:"on_failure"`        // refuse or quarantine
+}
+
+// RouteConfig maps the files matching a glob or regex pattern, relative to general.root_path,
+// to a repository. Routes are tried in order and the first match wins.
+type RouteConfig struct {
+	Name           string `mapstructure:"name"`
+	Pattern        string `mapstructure:"pattern"`
+	Regex          string `mapstructure:"regex"`
+	Repository     string `mapstructure:"repository"`
+	RepositoryType string `mapstructure:"repository_type"`
+	TargetPrefix   string `mapstructure:"target_prefix"`
+	ContentType    string `mapstructure:"content_type"` // Forced Content-Type, detected per file if empty
+}
+
+// ContentTypeRule sets the Content-Type of uploaded files by extension
+type ContentTypeRule struct {
+	Extensions []string `mapstructure:"extensions"` // With or without the leading dot, e.g. ".jar" or "tar.gz"
+	Type       string   `mapstructure:"type"`
 }
 
 // Config represents the application's configuration
@@ -44,34 +221,82 @@ type Config struct {
 		LogPath   string `mapstructure:"log_path"`
 		LogLevel  string `mapstructure:"log_level"`
 		BatchSize int    `mapstructure:"batch_size"`
+		DryRun    bool   `mapstructure:"dry_run"`
+		// File walk filters
+		Include       []string `mapstructure:"include"`
+		Exclude       []string `mapstructure:"exclude"`
+		MinSize       int64    `mapstructure:"min_size"`
+		MaxSize       int64    `mapstructure:"max_size"`
+		ModifiedSince string   `mapstructure:"modified_since"`
+		// Progress reporting of uploads, with log lines every progress_interval seconds when not on a terminal
+		Progress         string `mapstructure:"progress"`
+		ProgressInterval int    `mapstructure:"progress_interval"`
+		// RunTime is the start of the run, from which the ${date} and ${datetime} placeholders of
+		// raw.rewrite are expanded. Set by LoadConfig, not read from the file
+		RunTime time.Time `mapstructure:"-"`
 	} `mapstructure:"general"`
 	Nexus struct {
 		URL            string `mapstructure:"url"`
 		Repository     string `mapstructure:"repository"`
 		RepositoryType string `mapstructure:"repository_type"`
 		ForceReplace   bool   `mapstructure:"force_replace"`
+		TargetPrefix   string `mapstructure:"target_prefix"`
+		// VerifyChecksums compares every upload with the checksum Nexus reports for the asset
+		VerifyChecksums bool   `mapstructure:"verify_checksums"`
+		ContentType     string `mapstructure:"content_type"` // Forced Content-Type of the default route
 	} `mapstructure:"nexus"`
 	Retry RetryConfig `mapstructure:"retry"`
 	Proxy ProxyConfig `mapstructure:"proxy"`
+	TLS   TLSConfig   `mapstructure:"tls"`
 	Auth  AuthConfig  `mapstructure:"auth"`
+	Raw   RawConfig   `mapstructure:"raw"`
+	Apt   AptConfig   `mapstructure:"apt"`
+	Yum   YumConfig   `mapstructure:"yum"`
+	// Signing produces detached .asc signatures for raw and maven2 uploads
+	Signing SigningConfig `mapstructure:"signing"`
+	// Verification checks the .asc signatures found next to files before they are uploaded
+	Verification VerificationConfig `mapstructure:"verification"`
+	// Routes send matching files to other repositories; [nexus] is the default route.
+	Routes []RouteConfig `mapstructure:"route"`
+	// ContentTypes override the built-in Content-Type of file extensions
+	ContentTypes []ContentTypeRule `mapstructure:"content_type"`
+	// Network limits the bandwidth and request rate of uploads
+	Network NetworkConfig `mapstructure:"network"`
+	// Concurrency sets the parallelism of the upload worker pool
+	Concurrency ConcurrencyConfig `mapstructure:"concurrency"`
 }
 
 type RetryConfig struct {
 	RetryAttempts int `mapstructure:"retry_attempts"`
-	Timeout       int `mapstructure:"timeout"`
+	Timeout       int `mapstructure:"timeout"`     // Connection, TLS handshake and response header timeout, in seconds
+	Backoff       int `mapstructure:"backoff"`     // Delay before the first retry, in seconds, doubled after each retry
+	MaxBackoff    int `mapstructure:"max_backoff"` // Upper bound of the delay between attempts, in seconds
+}
+
+// repositoryTypes holds the repository types registered by importers, with their
+// optional validation of format-specific configuration sections.
+var repositoryTypes = map[string]func(cfg *Config) error{}
+
+// RegisterRepositoryType makes a repository type valid in the configuration.
+// It is called by the importer registry when a format registers itself.
+func RegisterRepositoryType(repoType string, validate func(cfg *Config) error) {
+	repositoryTypes[repoType] = validate
 }
 
-// SupportedRepositoryTypes defines all repository types currently supported by Iscrie.
-var SupportedRepositoryTypes = []string{"maven2", "raw"}
+// SupportedRepositoryTypes returns all repository types currently supported by Iscrie, sorted.
+func SupportedRepositoryTypes() []string {
+	types := make([]string, 0, len(repositoryTypes))
+	for repoType := range repositoryTypes {
+		types = append(types, repoType)
+	}
+	sort.Strings(types)
+	return types
+}
 
 // IsValidRepositoryType checks if the given repository type is supported.
 func IsValidRepositoryType(repoType string) bool {
-	for _, supportedType := range SupportedRepositoryTypes {
-		if repoType == supportedType {
-			return true
-		}
-	}
-	return false
+	_, ok := repositoryTypes[repoType]
+	return ok
 }
 
 // LoadConfig charges TOML configuration from a file
@@ -99,13 +324,20 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, utils.LogAndReturnError("failed to parse configuration: %w", err)
 	}
 
+	// Replace env:, file:, netrc: and exec: references by the secrets they point to
+	if err := resolveSecrets(&cfg); err != nil {
+		return nil, err
+	}
+	registerSecrets(&cfg)
+	cfg.General.RunTime = time.Now()
+
 	if err := validateConfig(&cfg); err != nil {
 		return nil, err
 	}
 
 	paths, err := utils.ConvertPathsToAbsolute(cfg.General.RootPath, cfg.General.LogPath)
 	if err != nil {
-		fmt.Printf("Error converting paths to absolute: %v\n", err)
+		fmt.Printf("Error converting paths to absolute: %s\n", utils.Redact(err.Error()))
 		return nil, err
 	}
 	cfg.General.RootPath = paths[0]
@@ -118,11 +350,34 @@ func LoadConfig(configPath string) (*Config, error) {
 func setDefaults() {
 	viper.SetDefault("general.log_level", "info")
 	viper.SetDefault("general.batch_size", DefaultBatchSize)
+	viper.SetDefault("general.dry_run", false)
+	viper.SetDefault("general.progress", ProgressAuto)
+	viper.SetDefault("general.progress_interval", 10)
 	viper.SetDefault("retry.retry_attempts", DefaultRetryAttempts)
 	viper.SetDefault("retry.timeout", DefaultRetryTimeout)
+	viper.SetDefault("retry.backoff", DefaultRetryBackoff)
+	viper.SetDefault("retry.max_backoff", DefaultMaxBackoff)
 	viper.SetDefault("proxy.enabled", false)
+	viper.SetDefault("proxy.scheme", "http")
+	viper.SetDefault("proxy.use_environment", true)
+	viper.SetDefault("auth.oauth2.auth_style", "header")
+	viper.SetDefault("auth.oauth2.refresh_before", 60)
+	viper.SetDefault("tls.min_version", "1.2")
+	viper.SetDefault("tls.insecure_skip_verify", false)
+	viper.SetDefault("concurrency.mode", ConcurrencyFixed)
+	viper.SetDefault("concurrency.min", 1)
+	viper.SetDefault("concurrency.max", DefaultMaxConcurrency)
+	viper.SetDefault("concurrency.latency_factor", 3.0)
+	viper.SetDefault("concurrency.decrease_factor", 0.5)
 	viper.SetDefault("nexus.repository_type", "raw")
 	viper.SetDefault("nexus.force_replace", false)
+	viper.SetDefault("nexus.verify_checksums", false)
+	viper.SetDefault("yum.repodata_depth", 0)
+	viper.SetDefault("yum.require_signature", false)
+	viper.SetDefault("signing.enabled", false)
+	viper.SetDefault("verification.enabled", false)
+	viper.SetDefault("verification.require_signature", true)
+	viper.SetDefault("verification.on_failure", OnFailureRefuse)
 	fmt.Println("Default configuration values applied.")
 }
 
@@ -134,6 +389,15 @@ func validateConfig(cfg *Config) error {
 	if cfg.General.BatchSize <= 0 || cfg.General.BatchSize > MaxBatchSize {
 		return utils.LogAndReturnError("general.batch_size must be between 1 and %d", MaxBatchSize)
 	}
+	if err := validateFilters(cfg); err != nil {
+		return err
+	}
+	if !slices.Contains(ProgressModes, cfg.General.Progress) {
+		return utils.LogAndReturnError("general.progress must be one of %s, got '%s'", strings.Join(ProgressModes, ", "), cfg.General.Progress)
+	}
+	if cfg.General.ProgressInterval <= 0 {
+		return utils.LogAndReturnError("general.progress_interval must be positive")
+	}
 	if cfg.Nexus.URL == "" {
 		return errors.New("missing required field: nexus.url")
 	}
@@ -141,11 +405,21 @@ func validateConfig(cfg *Config) error {
 		return errors.New("missing required field: nexus.repository")
 	}
 
-	switch cfg.Nexus.RepositoryType {
-	case "raw", "maven2":
-		// Valid types
-	default:
-		return utils.LogAndReturnError("invalid nexus.repository_type: %s. Valid options are 'raw' or 'maven2'", cfg.Nexus.RepositoryType)
+	if err := validateRepositoryType(cfg, "nexus.repository_type", cfg.Nexus.RepositoryType); err != nil {
+		return err
+	}
+	if err := validateRoutes(cfg); err != nil {
+		return err
+	}
+	if err := validateContentTypes(cfg); err != nil {
+		return err
+	}
+
+	if err := validateSigningConfig(&cfg.Signing); err != nil {
+		return err
+	}
+	if err := validateVerificationConfig(&cfg.Verification); err != nil {
+		return err
 	}
 
 	if cfg.Retry.RetryAttempts < 0 {
@@ -154,17 +428,357 @@ func validateConfig(cfg *Config) error {
 	if cfg.Retry.Timeout <= 0 {
 		return errors.New("retry.timeout must be greater than zero")
 	}
+	if cfg.Retry.Backoff < 0 {
+		return errors.New("retry.backoff cannot be negative")
+	}
+	if cfg.Retry.MaxBackoff < cfg.Retry.Backoff {
+		return errors.New("retry.max_backoff cannot be less than retry.backoff")
+	}
+
+	if err := validateProxyConfig(&cfg.Proxy); err != nil {
+		return err
+	}
+	if err := validateTLSConfig(&cfg.TLS); err != nil {
+		return err
+	}
+	if err := validateNetworkConfig(&cfg.Network); err != nil {
+		return err
+	}
+	if err := validateConcurrencyConfig(&cfg.Concurrency); err != nil {
+		return err
+	}
 
-	if cfg.Proxy.Enabled {
-		if cfg.Proxy.Host == "" {
-			return errors.New("proxy.host is required if proxy is enabled")
+	return validateAuthConfig(&cfg.Auth)
+}
+
+// validateFilters checks the include/exclude patterns and the size and date filters of [general].
+func validateFilters(cfg *Config) error {
+	for _, pattern := range append(append([]string{}, cfg.General.Include...), cfg.General.Exclude...) {
+		if _, err := utils.ParseIgnorePattern(pattern); err != nil {
+			return utils.LogAndReturnError("invalid general.include/exclude pattern '%s': %w", pattern, err)
 		}
-		if cfg.Proxy.Port <= 0 {
-			return errors.New("proxy.port must be greater than zero")
+	}
+	if cfg.General.MinSize < 0 || cfg.General.MaxSize < 0 {
+		return errors.New("general.min_size and general.max_size cannot be negative")
+	}
+	if cfg.General.MaxSize > 0 && cfg.General.MinSize > cfg.General.MaxSize {
+		return errors.New("general.min_size cannot be greater than general.max_size")
+	}
+	if cfg.General.ModifiedSince != "" {
+		if _, err := ParseModifiedSince(cfg.General.ModifiedSince); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return validateAuthConfig(&cfg.Auth)
+// ParseModifiedSince parses general.modified_since, an RFC 3339 timestamp or a YYYY-MM-DD date.
+func ParseModifiedSince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, utils.LogAndReturnError("invalid general.modified_since '%s': expected RFC 3339 or YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// validateRepositoryType checks that a repository type is registered and runs its validator.
+func validateRepositoryType(cfg *Config, field, repoType string) error {
+	validate, ok := repositoryTypes[repoType]
+	if !ok {
+		return utils.LogAndReturnError("invalid %s: %s. Valid options are: %s", field, repoType, strings.Join(SupportedRepositoryTypes(), ", "))
+	}
+	if validate != nil {
+		return validate(cfg)
+	}
+	return nil
+}
+
+// validateRoutes checks every [[route]] entry and names unnamed routes after their position.
+func validateRoutes(cfg *Config) error {
+	names := map[string]bool{DefaultRouteName: true}
+	for i := range cfg.Routes {
+		route := &cfg.Routes[i]
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route-%d", i+1)
+		}
+		if names[route.Name] {
+			return utils.LogAndReturnError("route name '%s' is used more than once", route.Name)
+		}
+		names[route.Name] = true
+
+		if (route.Pattern == "") == (route.Regex == "") {
+			return utils.LogAndReturnError("route '%s' requires exactly one of pattern or regex", route.Name)
+		}
+		if route.Pattern != "" {
+			if _, err := utils.CompileGlob(route.Pattern); err != nil {
+				return utils.LogAndReturnError("route '%s' has an invalid pattern: %w", route.Name, err)
+			}
+		}
+		if route.Regex != "" {
+			if _, err := regexp.Compile(route.Regex); err != nil {
+				return utils.LogAndReturnError("route '%s' has an invalid regex: %w", route.Name, err)
+			}
+		}
+		if route.Repository == "" {
+			return utils.LogAndReturnError("missing required field: route '%s' repository", route.Name)
+		}
+		if route.RepositoryType == "" {
+			route.RepositoryType = cfg.Nexus.RepositoryType
+		}
+		if err := validateRepositoryType(cfg, fmt.Sprintf("repository_type for route '%s'", route.Name), route.RepositoryType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateContentTypes(cfg *Config) error {
+	if err := validateMediaType("nexus.content_type", cfg.Nexus.ContentType); err != nil {
+		return err
+	}
+	for _, route := range cfg.Routes {
+		if err := validateMediaType(fmt.Sprintf("content_type for route '%s'", route.Name), route.ContentType); err != nil {
+			return err
+		}
+	}
+	for i := range cfg.ContentTypes {
+		rule := &cfg.ContentTypes[i]
+		if len(rule.Extensions) == 0 || rule.Type == "" {
+			return utils.LogAndReturnError("content_type rule %d requires extensions and a type", i+1)
+		}
+		if err := validateMediaType(fmt.Sprintf("content_type rule %d", i+1), rule.Type); err != nil {
+			return err
+		}
+		for j, extension := range rule.Extensions {
+			extension = strings.ToLower(strings.TrimSpace(extension))
+			if extension == "" || extension == "." {
+				return utils.LogAndReturnError("content_type rule %d has an empty extension", i+1)
+			}
+			if !strings.HasPrefix(extension, ".") {
+				extension = "." + extension
+			}
+			rule.Extensions[j] = extension
+		}
+	}
+	return nil
+}
+
+// ContentTypeMap returns the configured Content-Type of each extension; later rules win.
+func (cfg *Config) ContentTypeMap() map[string]string {
+	contentTypes := map[string]string{}
+	for _, rule := range cfg.ContentTypes {
+		for _, extension := range rule.Extensions {
+			contentTypes[extension] = rule.Type
+		}
+	}
+	return contentTypes
+}
+
+func validateMediaType(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, _, err := mime.ParseMediaType(value); err != nil {
+		return utils.LogAndReturnError("%s is not a valid media type: %w", field, err)
+	}
+	return nil
+}
+
+func validateSigningConfig(signing *SigningConfig) error {
+	if !signing.Enabled {
+		return nil
+	}
+	if signing.Keyring == "" {
+		return errors.New("signing.keyring is required if signing is enabled")
+	}
+	if _, err := os.Stat(signing.Keyring); err != nil {
+		return utils.LogAndReturnError("signing.keyring is not readable: %w", err)
+	}
+	if signing.PassphraseEnv != "" && signing.PassphraseFile != "" {
+		return errors.New("only one of signing.passphrase_env and signing.passphrase_file should be configured")
+	}
+	return nil
+}
+
+func validateVerificationConfig(verification *VerificationConfig) error {
+	if !verification.Enabled {
+		return nil
+	}
+	if verification.Keyring == "" {
+		return errors.New("verification.keyring is required if verification is enabled")
+	}
+	if _, err := os.Stat(verification.Keyring); err != nil {
+		return utils.LogAndReturnError("verification.keyring is not readable: %w", err)
+	}
+	switch verification.OnFailure {
+	case OnFailureRefuse, OnFailureQuarantine:
+	default:
+		return utils.LogAndReturnError("verification.on_failure must be '%s' or '%s', got '%s'", OnFailureRefuse, OnFailureQuarantine, verification.OnFailure)
+	}
+	return nil
+}
+
+func validateProxyConfig(proxy *ProxyConfig) error {
+	for _, entry := range proxy.NoProxy {
+		if host, _, err := net.SplitHostPort(entry); err == nil {
+			entry = host
+		}
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return utils.LogAndReturnError("invalid proxy.no_proxy entry: %w", err)
+			}
+		}
+	}
+	if !proxy.Enabled {
+		return nil
+	}
+	if proxy.Host == "" {
+		return errors.New("proxy.host is required if proxy is enabled")
+	}
+	if proxy.Port <= 0 {
+		return errors.New("proxy.port must be greater than zero")
+	}
+	if !slices.Contains(ProxySchemes, proxy.Scheme) {
+		return utils.LogAndReturnError("proxy.scheme must be one of %s, got '%s'", strings.Join(ProxySchemes, ", "), proxy.Scheme)
+	}
+	if (proxy.Username == "") != (proxy.Password == "") {
+		return errors.New("proxy.username and proxy.password must both be provided or left empty")
+	}
+	if (proxy.HeaderName == "") != (proxy.HeaderValue == "") {
+		return errors.New("proxy.header_name and proxy.header_value must both be provided or left empty")
+	}
+	if proxy.Username != "" && proxy.HeaderName != "" {
+		return errors.New("only one proxy authentication method should be configured")
+	}
+	if proxy.HeaderName != "" && strings.HasPrefix(proxy.Scheme, "socks5") {
+		return errors.New("proxy.header_name is not supported by socks5 proxies")
+	}
+	return nil
+}
+
+func validateNetworkConfig(network *NetworkConfig) error {
+	if err := validateRateLimits("network", network.RateLimits); err != nil {
+		return err
+	}
+	for i, schedule := range network.Schedules {
+		field := fmt.Sprintf("network.schedule %d", i+1)
+		start, err := ParseTimeOfDay(schedule.Start)
+		if err != nil {
+			return utils.LogAndReturnError("%s: invalid start: %w", field, err)
+		}
+		end, err := ParseTimeOfDay(schedule.End)
+		if err != nil {
+			return utils.LogAndReturnError("%s: invalid end: %w", field, err)
+		}
+		if start == end {
+			return utils.LogAndReturnError("%s: start and end must differ", field)
+		}
+		if err := validateRateLimits(field, schedule.RateLimits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRateLimits(field string, limits RateLimits) error {
+	if limits.BytesPerSecond < 0 || limits.PerHostBytesPerSecond < 0 || limits.RequestsPerSecond < 0 || limits.PerHostRequestsPerSecond < 0 {
+		return utils.LogAndReturnError("%s: rate limits cannot be negative", field)
+	}
+	return nil
+}
+
+func validateConcurrencyConfig(concurrency *ConcurrencyConfig) error {
+	if !slices.Contains(ConcurrencyModes, concurrency.Mode) {
+		return utils.LogAndReturnError("concurrency.mode must be one of %s, got '%s'", strings.Join(ConcurrencyModes, ", "), concurrency.Mode)
+	}
+	if concurrency.Mode != ConcurrencyAdaptive {
+		return nil
+	}
+	if concurrency.Min < 1 || concurrency.Max < concurrency.Min || concurrency.Max > MaxBatchSize {
+		return utils.LogAndReturnError("concurrency.min and concurrency.max must satisfy 1 <= min <= max <= %d", MaxBatchSize)
+	}
+	if concurrency.LatencyFactor <= 1 {
+		return utils.LogAndReturnError("concurrency.latency_factor must be greater than 1")
+	}
+	if concurrency.DecreaseFactor <= 0 || concurrency.DecreaseFactor >= 1 {
+		return utils.LogAndReturnError("concurrency.decrease_factor must be between 0 and 1, exclusive")
+	}
+	return nil
+}
+
+// ParseTimeOfDay parses an HH:MM time of day into its offset from midnight.
+func ParseTimeOfDay(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got '%s'", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+func validateTLSConfig(tlsConfig *TLSConfig) error {
+	for _, caFile := range tlsConfig.CAFiles {
+		if _, err := os.Stat(caFile); err != nil {
+			return utils.LogAndReturnError("tls.ca_files entry is not readable: %w", err)
+		}
+	}
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		return errors.New("tls.cert_file and tls.key_file must be configured together")
+	}
+	for _, file := range []string{tlsConfig.CertFile, tlsConfig.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return utils.LogAndReturnError("tls client certificate is not readable: %w", err)
+		}
+	}
+	if tlsConfig.MinVersion != "" && !slices.Contains(TLSVersions, tlsConfig.MinVersion) {
+		return utils.LogAndReturnError("tls.min_version must be one of %s, got '%s'", strings.Join(TLSVersions, ", "), tlsConfig.MinVersion)
+	}
+	for _, pin := range tlsConfig.PinnedSPKI {
+		if _, err := ParseSPKIPin(pin); err != nil {
+			return utils.LogAndReturnError("invalid tls.pinned_spki entry '%s': %w", pin, err)
+		}
+	}
+	if len(tlsConfig.PinnedSPKI) > 0 && tlsConfig.InsecureSkipVerify {
+		// Pins are matched against the verified chain, which does not exist without verification
+		return utils.LogAndReturnError("tls.pinned_spki cannot be combined with tls.insecure_skip_verify")
+	}
+	return nil
+}
+
+// ParseSPKIPin decodes a "sha256/<base64>" pin, or a bare base64 SHA-256, into the hash it holds.
+func ParseSPKIPin(pin string) ([]byte, error) {
+	hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256/"))
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) != sha256.Size {
+		return nil, fmt.Errorf("expected a SHA-256 hash of %d bytes, got %d", sha256.Size, len(hash))
+	}
+	return hash, nil
+}
+
+func validateOAuth2Config(oauth2 *OAuth2Config) error {
+	if oauth2.TokenURL == "" || oauth2.ClientID == "" {
+		return errors.New("auth.type 'oauth2' requires auth.oauth2.token_url and auth.oauth2.client_id")
+	}
+	if tokenURL, err := url.Parse(oauth2.TokenURL); err != nil || (tokenURL.Scheme != "http" && tokenURL.Scheme != "https") || tokenURL.Host == "" {
+		return utils.LogAndReturnError("auth.oauth2.token_url must be an absolute http or https URL")
+	}
+	if oauth2.RefreshToken == "" && oauth2.ClientSecret == "" {
+		return errors.New("auth.oauth2 requires client_secret, or refresh_token for public clients")
+	}
+	if !slices.Contains(OAuth2AuthStyles, oauth2.AuthStyle) {
+		return utils.LogAndReturnError("auth.oauth2.auth_style must be one of %s, got '%s'", strings.Join(OAuth2AuthStyles, ", "), oauth2.AuthStyle)
+	}
+	if oauth2.RefreshBefore < 0 {
+		return errors.New("auth.oauth2.refresh_before cannot be negative")
+	}
+	return nil
 }
 
 func validateAuthConfig(auth *AuthConfig) error {
@@ -181,8 +795,12 @@ func validateAuthConfig(auth *AuthConfig) error {
 		if auth.HeaderName == "" || auth.HeaderValue == "" {
 			return errors.New("auth.type 'header' requires both header_name and header_value")
 		}
+	case "oauth2":
+		if err := validateOAuth2Config(&auth.OAuth2); err != nil {
+			return err
+		}
 	default:
-		return utils.LogAndReturnError("invalid auth.type: %s. Valid options are 'basic', 'bearer', 'header'", auth.Type)
+		return utils.LogAndReturnError("invalid auth.type: %s. Valid options are 'basic', 'bearer', 'header', 'oauth2'", auth.Type)
 	}
 
 	if count := utils.CountNonEmpty(auth.UserToken, auth.PassToken, auth.AccessToken, auth.HeaderName, auth.HeaderValue); count > 2 {
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"iscrie/utils"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Prefixes of the secret references accepted by the secret fields of [auth] and [proxy]
+const (
+	SecretEnv   = "env:"   // env:VAR reads an environment variable
+	SecretFile  = "file:"  // file:/path reads a file, without its trailing newline
+	SecretNetrc = "netrc:" // netrc: reads the entry of the host in ~/.netrc, or $NETRC
+	SecretExec  = "exec:"  // exec:command args runs a credential helper printing JSON
+)
+
+// helperTimeout bounds the run time of a credential helper.
+const helperTimeout = 30 * time.Second
+
+// secretField is a field of the configuration that may hold a secret reference.
+type secretField struct {
+	name  string  // Configuration key, for error messages
+	value *string // Field to resolve in place
+	host  string  // Host whose netrc entry or helper credentials are used
+	login bool    // The field is a user name: netrc login or helper username
+}
+
+// secretResolver resolves the secret references of a configuration, running each helper once.
+type secretResolver struct {
+	netrc   map[string]netrcEntry // Loaded on first use
+	helpers map[string]helperCredentials
+}
+
+// netrcEntry is a machine entry of a netrc file.
+type netrcEntry struct {
+	login, password string
+}
+
+// helperCredentials is the JSON printed by a credential helper.
+type helperCredentials struct {
+	Username string `json:"username"`
+	Secret   string `json:"secret"`
+}
+
+// resolveSecrets replaces the secret references of [auth], [auth.oauth2] and [proxy] by their value.
+// Errors name the field and the reference, never the resolved value.
+func resolveSecrets(cfg *Config) error {
+	nexusHost := hostOf(cfg.Nexus.URL)
+	tokenHost := hostOf(cfg.Auth.OAuth2.TokenURL)
+	fields := []secretField{
+		{name: "auth.user_token", value: &cfg.Auth.UserToken, host: nexusHost, login: true},
+		{name: "auth.pass_token", value: &cfg.Auth.PassToken, host: nexusHost},
+		{name: "auth.access_token", value: &cfg.Auth.AccessToken, host: nexusHost},
+		{name: "auth.header_value", value: &cfg.Auth.HeaderValue, host: nexusHost},
+		{name: "auth.oauth2.client_id", value: &cfg.Auth.OAuth2.ClientID, host: tokenHost, login: true},
+		{name: "auth.oauth2.client_secret", value: &cfg.Auth.OAuth2.ClientSecret, host: tokenHost},
+		{name: "auth.oauth2.refresh_token", value: &cfg.Auth.OAuth2.RefreshToken, host: tokenHost},
+		{name: "proxy.username", value: &cfg.Proxy.Username, host: cfg.Proxy.Host, login: true},
+		{name: "proxy.password", value: &cfg.Proxy.Password, host: cfg.Proxy.Host},
+		{name: "proxy.header_value", value: &cfg.Proxy.HeaderValue, host: cfg.Proxy.Host},
+	}
+
+	resolver := &secretResolver{helpers: map[string]helperCredentials{}}
+	for _, field := range fields {
+		value, err := resolver.resolve(field)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.name, err)
+		}
+		*field.value = value
+	}
+	return nil
+}
+
+// registerSecrets makes the secrets of the configuration masked in the logs, along with the
+// credentials of the Basic authentication headers built from them.
+func registerSecrets(cfg *Config) {
+	utils.RegisterSecret(cfg.Auth.PassToken, cfg.Auth.AccessToken, cfg.Auth.HeaderValue,
+		cfg.Auth.OAuth2.ClientSecret, cfg.Auth.OAuth2.RefreshToken, cfg.Proxy.Password, cfg.Proxy.HeaderValue)
+	if cfg.Auth.PassToken != "" {
+		utils.RegisterSecret(basicCredentials(cfg.Auth.UserToken, cfg.Auth.PassToken))
+	}
+	if cfg.Auth.OAuth2.ClientSecret != "" {
+		utils.RegisterSecret(basicCredentials(url.QueryEscape(cfg.Auth.OAuth2.ClientID), url.QueryEscape(cfg.Auth.OAuth2.ClientSecret)))
+	}
+	if cfg.Proxy.Password != "" {
+		utils.RegisterSecret(basicCredentials(cfg.Proxy.Username, cfg.Proxy.Password))
+	}
+	utils.RegisterSecretHeader(cfg.Auth.HeaderName, cfg.Proxy.HeaderName)
+}
+
+// basicCredentials returns the credentials of a Basic authentication header.
+func basicCredentials(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+// resolve returns the value of a field: the secret it references, or the field itself.
+func (r *secretResolver) resolve(field secretField) (string, error) {
+	reference := *field.value
+	switch {
+	case strings.HasPrefix(reference, SecretEnv):
+		name := strings.TrimPrefix(reference, SecretEnv)
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+
+	case strings.HasPrefix(reference, SecretFile):
+		path, err := expandHome(strings.TrimPrefix(reference, SecretFile))
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		value := strings.TrimRight(string(content), "\r\n")
+		if value == "" {
+			return "", fmt.Errorf("secret file %s is empty", path)
+		}
+		return value, nil
+
+	case strings.HasPrefix(reference, SecretNetrc):
+		entry, err := r.netrcEntry(field.host)
+		if err != nil {
+			return "", err
+		}
+		key, value := "password", entry.password
+		if field.login {
+			key, value = "login", entry.login
+		}
+		if value == "" {
+			return "", fmt.Errorf("netrc entry for machine %s has no %s", field.host, key)
+		}
+		return value, nil
+
+	case strings.HasPrefix(reference, SecretExec):
+		credentials, err := r.runHelper(strings.TrimPrefix(reference, SecretExec), field.host)
+		if err != nil {
+			return "", err
+		}
+		key, value := "secret", credentials.Secret
+		if field.login {
+			key, value = "username", credentials.Username
+		}
+		if value == "" {
+			return "", fmt.Errorf("credential helper '%s' returned no %s", strings.TrimPrefix(reference, SecretExec), key)
+		}
+		return value, nil
+	}
+	return reference, nil
+}
+
+// netrcEntry returns the entry of a host in the netrc file, or its default entry.
+func (r *secretResolver) netrcEntry(host string) (netrcEntry, error) {
+	if host == "" {
+		return netrcEntry{}, fmt.Errorf("no host to look up in netrc")
+	}
+	if r.netrc == nil {
+		path := os.Getenv("NETRC")
+		if path == "" {
+			var err error
+			if path, err = expandHome("~/.netrc"); err != nil {
+				return netrcEntry{}, err
+			}
+		}
+		entries, err := parseNetrc(path)
+		if err != nil {
+			return netrcEntry{}, err
+		}
+		r.netrc = entries
+	}
+	if entry, ok := r.netrc[strings.ToLower(host)]; ok {
+		return entry, nil
+	}
+	if entry, ok := r.netrc[""]; ok {
+		return entry, nil
+	}
+	return netrcEntry{}, fmt.Errorf("no netrc entry for machine %s", host)
+}
+
+// parseNetrc reads the machine and default entries of a netrc file, keyed by lower case host name,
+// the default entry under "". Macro definitions are skipped.
+func parseNetrc(path string) (map[string]netrcEntry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read netrc file: %w", err)
+	}
+
+	entries := map[string]netrcEntry{}
+	var machine *string
+	var entry netrcEntry
+	save := func() {
+		if machine != nil {
+			if _, seen := entries[*machine]; !seen {
+				entries[*machine] = entry // The first entry of a machine wins
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != "" // A macro ends at the first empty line
+			continue
+		}
+		tokens := strings.Fields(line)
+		for i := 0; i < len(tokens); i++ {
+			if strings.HasPrefix(tokens[i], "#") {
+				break
+			}
+			next := ""
+			if i+1 < len(tokens) {
+				next = tokens[i+1]
+			}
+			switch tokens[i] {
+			case "machine":
+				save()
+				host := strings.ToLower(next)
+				machine, entry = &host, netrcEntry{}
+				i++
+			case "default":
+				save()
+				host := ""
+				machine, entry = &host, netrcEntry{}
+			case "login":
+				entry.login = next
+				i++
+			case "password":
+				entry.password = next
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(tokens)
+			}
+		}
+	}
+	save()
+	return entries, scanner.Err()
+}
+
+// runHelper runs a credential helper once per command line. The host is written on its standard
+// input; it prints a JSON object with "username" and "secret". The command is split on spaces and
+// not run by a shell. Its output is never included in errors.
+func (r *secretResolver) runHelper(commandLine, host string) (helperCredentials, error) {
+	key := commandLine + "\x00" + host
+	if credentials, ok := r.helpers[key]; ok {
+		return credentials, nil
+	}
+	args := strings.Fields(commandLine)
+	if len(args) == 0 {
+		return helperCredentials{}, fmt.Errorf("empty credential helper command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), helperTimeout)
+	defer cancel()
+	command := exec.CommandContext(ctx, args[0], args[1:]...)
+	command.Stdin = strings.NewReader(host + "\n")
+	command.Stderr = os.Stderr
+	output, err := command.Output()
+	if err != nil {
+		return helperCredentials{}, fmt.Errorf("credential helper '%s' failed: %w", args[0], err)
+	}
+
+	var credentials helperCredentials
+	if err := json.Unmarshal(output, &credentials); err != nil {
+		return helperCredentials{}, fmt.Errorf("credential helper '%s' did not print a JSON object", args[0])
+	}
+	r.helpers[key] = credentials
+	return credentials, nil
+}
+
+// hostOf returns the host name of a URL, or "" if it has none.
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+// expandHome replaces a leading ~ by the home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the home directory: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
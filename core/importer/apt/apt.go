@@ -0,0 +1,130 @@
+package apt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iscrie/config"
+	"iscrie/core/importer"
+	"iscrie/network"
+	"iscrie/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AptImporter handles importing Debian packages into a Nexus apt hosted repository.
+type AptImporter struct {
+	BaseURL       string
+	Repository    string
+	HTTPClient    *network.HTTPClientAdapter
+	RootPath      string
+	ForceReplace  bool
+	Architectures []string
+}
+
+// NewAptImporter creates a new AptImporter instance.
+func NewAptImporter(baseURL, repository, rootPath string, httpClient *network.HTTPClient, forceReplace bool, architectures []string) *AptImporter {
+	adapter := network.NewHTTPClientAdapter(httpClient, baseURL, repository, forceReplace)
+
+	return &AptImporter{
+		BaseURL:       baseURL,
+		Repository:    repository,
+		HTTPClient:    adapter,
+		RootPath:      rootPath,
+		ForceReplace:  forceReplace,
+		Architectures: architectures,
+	}
+}
+
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "apt",
+		Match: func(filePath string) bool {
+			return strings.EqualFold(filepath.Ext(filePath), ".deb")
+		},
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return NewAptImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace, cfg.Apt.Architectures).UploadAptFile
+		},
+		ValidateConfig: func(cfg *config.Config) error {
+			if len(cfg.Apt.Architectures) == 0 {
+				return errors.New("apt.architectures is required when nexus.repository_type is 'apt'")
+			}
+			return nil
+		},
+	})
+}
+
+// BuildTargetURL returns the repository root URL.
+// Nexus apt places the package in its pool from the control metadata, so the local path is not used.
+func (ai *AptImporter) BuildTargetURL() string {
+	return fmt.Sprintf("%srepository/%s/", utils.NormalizeBaseURL(ai.BaseURL), ai.Repository)
+}
+
+// IsAllowedArchitecture checks the package architecture against the configured ones.
+// Architecture-independent packages ("all") are accepted by every apt repository.
+func (ai *AptImporter) IsAllowedArchitecture(architecture string) bool {
+	if architecture == "all" {
+		return true
+	}
+	for _, allowed := range ai.Architectures {
+		if architecture == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// InspectDebFile reads and validates the control metadata of a .deb package.
+func (ai *AptImporter) InspectDebFile(filePath string) (ControlFields, error) {
+	if !strings.EqualFold(filepath.Ext(filePath), ".deb") {
+		return nil, NewAptError(filePath, "", "", "", "not a Debian package (.deb)")
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, NewAptError(filePath, "", "", "", fmt.Sprintf("failed to open file: %v", err))
+	}
+	defer file.Close()
+
+	fields, err := ReadDebControl(file)
+	if err != nil {
+		return nil, NewAptError(filePath, "", "", "", fmt.Sprintf("failed to read control file: %v", err))
+	}
+
+	if err := ValidateControl(fields); err != nil {
+		return nil, NewAptError(filePath, fields["Package"], fields["Version"], fields["Architecture"], err.Error())
+	}
+
+	if !ai.IsAllowedArchitecture(fields["Architecture"]) {
+		return nil, NewAptError(filePath, fields["Package"], fields["Version"], fields["Architecture"],
+			fmt.Sprintf("architecture not configured for repository (allowed: %s)", strings.Join(ai.Architectures, ", ")))
+	}
+
+	return fields, nil
+}
+
+// UploadAptFile validates a .deb package and uploads it to Nexus with retry logic.
+func (ai *AptImporter) UploadAptFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Inspect the control metadata
+	fields, err := ai.InspectDebFile(filePath)
+	if err != nil {
+		errorLogger("Rejected Debian package '%s': %v", filePath, err)
+		return fmt.Errorf("invalid Debian package: %w", err)
+	}
+	debugLogger("Parsed package - Package: %s, Version: %s, Architecture: %s",
+		fields["Package"], fields["Version"], fields["Architecture"])
+
+	// Step 2: POST the package to the repository root
+	targetURL := ai.BuildTargetURL()
+	debugLogger("Target URL: %s", targetURL)
+	return importer.PostFileWithRetry(ctx, ai.HTTPClient, targetURL, filePath, retryAttempts, debugLogger, errorLogger)
+}
@@ -0,0 +1,42 @@
+package apt
+
+import (
+	"fmt"
+	"iscrie/core/importer"
+	"iscrie/utils"
+)
+
+// AptError represents specific errors for apt repository.
+type AptError struct {
+	importer.ImportError        // Use generic values
+	Package              string `json:"package,omitempty"`
+	Version              string `json:"version,omitempty"`
+	Architecture         string `json:"architecture,omitempty"`
+}
+
+// NewAptError creates AptError instances.
+func NewAptError(filePath, pkg, version, architecture, errorMessage string) AptError {
+	return AptError{
+		ImportError: importer.ImportError{
+			FilePath:       filePath,
+			RepositoryType: "apt",
+			Error:          errorMessage,
+		},
+		Package:      pkg,
+		Version:      version,
+		Architecture: architecture,
+	}
+}
+
+func FormatAptErrorMessage(e AptError) string {
+	return fmt.Sprintf(
+		"Apt Error - File: %s, Package: %s, Version: %s, Architecture: %s, Error: %s",
+		e.FilePath, e.Package, e.Version, e.Architecture, e.ImportError.Error,
+	)
+}
+
+func (e AptError) Error() string {
+	formattedMessage := FormatAptErrorMessage(e)
+	utils.LogError(formattedMessage)
+	return formattedMessage
+}
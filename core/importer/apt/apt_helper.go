@@ -0,0 +1,140 @@
+package apt
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"iscrie/core/importer"
+	"iscrie/utils"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	arMagic        = "!<arch>\n"
+	arHeaderLength = 60
+)
+
+var (
+	packageNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+$`)
+	versionRegex     = regexp.MustCompile(`^(\d+:)?[0-9][A-Za-z0-9.+~:-]*$`)
+)
+
+// ControlFields holds the fields of a Debian package control file.
+type ControlFields map[string]string
+
+// ReadDebControl extracts and parses the control file of an ar-format .deb package.
+func ReadDebControl(r io.Reader) (ControlFields, error) {
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != arMagic {
+		return nil, utils.LogAndReturnError("not an ar archive: missing '!<arch>' magic")
+	}
+
+	header := make([]byte, arHeaderLength)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil, utils.LogAndReturnError("control archive not found in .deb package")
+			}
+			return nil, utils.LogAndReturnError("failed to read ar member header: %w", err)
+		}
+		if string(header[58:60]) != "`\n" {
+			return nil, utils.LogAndReturnError("corrupt ar member header")
+		}
+
+		name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil || size < 0 {
+			return nil, utils.LogAndReturnError("invalid size for ar member '%s'", name)
+		}
+		member := io.LimitReader(r, size)
+
+		if strings.HasPrefix(name, "control.tar") {
+			return readControlTar(name, member)
+		}
+
+		// Members are padded to an even offset
+		if _, err := io.Copy(io.Discard, member); err != nil {
+			return nil, utils.LogAndReturnError("failed to skip ar member '%s': %w", name, err)
+		}
+		if size%2 == 1 {
+			if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+				return nil, utils.LogAndReturnError("failed to skip ar padding: %w", err)
+			}
+		}
+	}
+}
+
+// readControlTar decompresses the control archive and parses its control file.
+func readControlTar(name string, r io.Reader) (ControlFields, error) {
+	var tarReader io.Reader
+	switch path.Ext(name) {
+	case ".tar":
+		tarReader = r
+	case ".gz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to open %s: %w", name, err)
+		}
+		defer gz.Close()
+		tarReader = gz
+	case ".xz":
+		xzReader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to open %s: %w", name, err)
+		}
+		tarReader = xzReader
+	case ".zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to open %s: %w", name, err)
+		}
+		defer zr.Close()
+		tarReader = zr
+	default:
+		return nil, utils.LogAndReturnError("unsupported control archive compression: %s", name)
+	}
+
+	tr := tar.NewReader(tarReader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, utils.LogAndReturnError("control file not found in %s", name)
+		}
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to read %s: %w", name, err)
+		}
+		if path.Clean(hdr.Name) == "control" {
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, utils.LogAndReturnError("failed to read control file: %w", err)
+			}
+			fields, err := importer.ParseDCF(content)
+			return ControlFields(fields), err
+		}
+	}
+}
+
+// ValidateControl checks the package, version and architecture fields.
+func ValidateControl(fields ControlFields) error {
+	pkg := fields["Package"]
+	version := fields["Version"]
+	architecture := fields["Architecture"]
+
+	if pkg == "" || !packageNameRegex.MatchString(pkg) {
+		return fmt.Errorf("invalid or missing Package field: %q", pkg)
+	}
+	if version == "" || !versionRegex.MatchString(version) {
+		return fmt.Errorf("invalid or missing Version field: %q", version)
+	}
+	if architecture == "" || strings.ContainsAny(architecture, " \t") {
+		return fmt.Errorf("invalid or missing Architecture field: %q", architecture)
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package importer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"iscrie/utils"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Archive extensions that can be used as a root path.
+var archiveExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.xz", ".tar.zst"}
+
+// ArchiveEntry is a regular file inside an archive, uploaded without being extracted.
+type ArchiveEntry struct {
+	Path string // Virtual path, <archive>/<name>, used for filtering, routing and target paths
+	Name string // Slash-separated path inside the archive
+	Info fs.FileInfo
+
+	open  func() (io.ReadCloser, error)
+	index *archiveIndex // Nil for entries created by NewArchiveEntry
+}
+
+// archiveIndex lists the names of the regular entries of an archive, on first use.
+type archiveIndex struct {
+	archivePath string
+	list        func() (map[string]bool, error)
+
+	once  sync.Once
+	names map[string]bool
+}
+
+// contains reports whether the archive has a regular entry with the given name.
+func (i *archiveIndex) contains(name string) bool {
+	i.once.Do(func() {
+		var err error
+		if i.names, err = i.list(); err != nil {
+			utils.LogError("Failed to list the entries of archive %s: %v", i.archivePath, err)
+		}
+	})
+	return i.names[name]
+}
+
+// NewArchiveEntry creates an entry whose content is returned by open, for importers reading archives themselves.
+func NewArchiveEntry(entryPath, name string, info fs.FileInfo, open func() (io.ReadCloser, error)) *ArchiveEntry {
+	return &ArchiveEntry{Path: entryPath, Name: name, Info: info, open: open}
+}
+
+// Open returns the content of the entry. It can be called again to replay the entry on retries.
+func (e *ArchiveEntry) Open() (io.ReadCloser, error) {
+	return e.open()
+}
+
+// HasSignatureEntry reports whether the archive also holds a signature of the entry, which is
+// uploaded as a regular entry rather than generated. The first call lists the archive.
+func (e *ArchiveEntry) HasSignatureEntry() bool {
+	if e.index == nil {
+		return false
+	}
+	return e.index.contains(e.Name + SignatureExtension)
+}
+
+// IsArchive reports whether a path is a regular file with a supported archive extension.
+func IsArchive(filePath string) bool {
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return archiveExtension(filePath) != ""
+}
+
+func archiveExtension(filePath string) string {
+	lower := strings.ToLower(filePath)
+	for _, extension := range archiveExtensions {
+		if strings.HasSuffix(lower, extension) {
+			return extension
+		}
+	}
+	return ""
+}
+
+// WalkArchive calls fn for every regular file of a zip or tar archive, in archive order.
+// Entries are read lazily: tar entries are streamed, never extracted to disk.
+func WalkArchive(archivePath string, fn func(entry *ArchiveEntry) error) error {
+	if archiveExtension(archivePath) == ".zip" {
+		return walkZip(archivePath, fn)
+	}
+	return walkTar(archivePath, fn)
+}
+
+// entryName validates an entry name and returns it cleaned, or "" if it escapes the archive.
+func entryName(name string) string {
+	cleaned := path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+	if path.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return ""
+	}
+	return cleaned
+}
+
+func walkZip(archivePath string, fn func(entry *ArchiveEntry) error) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return utils.LogAndReturnError("failed to open zip archive '%s': %w", archivePath, err)
+	}
+	defer reader.Close()
+
+	index := &archiveIndex{archivePath: archivePath, list: func() (map[string]bool, error) {
+		names := map[string]bool{}
+		for _, file := range reader.File {
+			if name := entryName(file.Name); name != "" && file.Mode().IsRegular() {
+				names[name] = true
+			}
+		}
+		return names, nil
+	}}
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		name := entryName(file.Name)
+		if name == "" {
+			utils.LogError("Skipping unsafe archive entry '%s' in %s", file.Name, archivePath)
+			continue
+		}
+		entry := &ArchiveEntry{
+			Path:  filepath.Join(archivePath, filepath.FromSlash(name)),
+			Name:  name,
+			Info:  file.FileInfo(),
+			open:  file.Open,
+			index: index,
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openTar opens a possibly compressed tar archive. The returned closer releases the file and decompressor.
+func openTar(archivePath string) (*tar.Reader, io.Closer, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stream io.Reader
+	closers := multiCloser{file}
+	switch archiveExtension(archivePath) {
+	case ".tar":
+		stream = file
+	case ".tar.gz", ".tgz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		stream = gz
+		closers = append(multiCloser{gz}, closers...)
+	case ".tar.xz":
+		xzReader, err := xz.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		stream = xzReader
+	case ".tar.zst":
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		stream = zr
+		closers = append(multiCloser{zstdCloser{zr}}, closers...)
+	default:
+		file.Close()
+		return nil, nil, fmt.Errorf("unsupported archive '%s'", archivePath)
+	}
+	return tar.NewReader(stream), closers, nil
+}
+
+func walkTar(archivePath string, fn func(entry *ArchiveEntry) error) error {
+	tr, closer, err := openTar(archivePath)
+	if err != nil {
+		return utils.LogAndReturnError("failed to open tar archive '%s': %w", archivePath, err)
+	}
+	defer closer.Close()
+
+	entries := &archiveIndex{archivePath: archivePath, list: func() (map[string]bool, error) { return listTar(archivePath) }}
+	for index := 0; ; index++ {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return utils.LogAndReturnError("failed to read tar archive '%s': %w", archivePath, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := entryName(header.Name)
+		if name == "" {
+			utils.LogError("Skipping unsafe archive entry '%s' in %s", header.Name, archivePath)
+			continue
+		}
+
+		// The first Open streams from the walk; later ones (retries) re-read the archive up to the entry
+		streamed := false
+		entryIndex := index
+		entry := &ArchiveEntry{
+			Path: filepath.Join(archivePath, filepath.FromSlash(name)),
+			Name: name,
+			Info: header.FileInfo(),
+			open: func() (io.ReadCloser, error) {
+				if !streamed {
+					streamed = true
+					return io.NopCloser(tr), nil
+				}
+				return reopenTarEntry(archivePath, entryIndex)
+			},
+			index: entries,
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// listTar reads the headers of a tar archive and returns the names of its regular entries.
+func listTar(archivePath string) (map[string]bool, error) {
+	tr, closer, err := openTar(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	names := map[string]bool{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if name := entryName(header.Name); name != "" && header.Typeflag == tar.TypeReg {
+			names[name] = true
+		}
+	}
+}
+
+// reopenTarEntry opens the archive again and skips to the entry at the given index.
+func reopenTarEntry(archivePath string, entryIndex int) (io.ReadCloser, error) {
+	tr, closer, err := openTar(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	for index := 0; index <= entryIndex; index++ {
+		if _, err := tr.Next(); err != nil {
+			closer.Close()
+			return nil, fmt.Errorf("failed to reopen archive entry %d: %w", entryIndex, err)
+		}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{tr, closer}, nil
+}
+
+type multiCloser []io.Closer
+
+func (mc multiCloser) Close() error {
+	var firstErr error
+	for _, closer := range mc {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// zstdCloser adapts zstd.Decoder, whose Close returns nothing.
+type zstdCloser struct {
+	decoder *zstd.Decoder
+}
+
+func (z zstdCloser) Close() error {
+	z.decoder.Close()
+	return nil
+}
@@ -0,0 +1,110 @@
+package cargo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"iscrie/config"
+	"iscrie/core/importer"
+	"iscrie/network"
+	"iscrie/utils"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CargoImporter handles publishing crates to a Nexus cargo hosted repository.
+type CargoImporter struct {
+	BaseURL      string
+	Repository   string
+	HTTPClient   *network.HTTPClientAdapter
+	RootPath     string
+	ForceReplace bool
+}
+
+// NewCargoImporter creates a new CargoImporter instance.
+func NewCargoImporter(baseURL, repository, rootPath string, httpClient *network.HTTPClient, forceReplace bool) *CargoImporter {
+	adapter := network.NewHTTPClientAdapter(httpClient, baseURL, repository, forceReplace)
+
+	return &CargoImporter{
+		BaseURL:      baseURL,
+		Repository:   repository,
+		HTTPClient:   adapter,
+		RootPath:     rootPath,
+		ForceReplace: forceReplace,
+	}
+}
+
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "cargo",
+		Match: func(filePath string) bool {
+			return strings.EqualFold(filepath.Ext(filePath), ".crate")
+		},
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return NewCargoImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace).UploadCrateFile
+		},
+	})
+}
+
+// BuildTargetURL returns the publish endpoint used by `cargo publish`.
+func (ci *CargoImporter) BuildTargetURL() string {
+	return fmt.Sprintf("%srepository/%s/api/v1/crates/new", utils.NormalizeBaseURL(ci.BaseURL), ci.Repository)
+}
+
+// InspectCrateFile reads the crate and builds its publish metadata from the embedded Cargo.toml.
+func (ci *CargoImporter) InspectCrateFile(filePath string) (*PublishMetadata, []byte, error) {
+	crate, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, NewCargoError(filePath, "", "", fmt.Sprintf("failed to read file: %v", err))
+	}
+
+	metadata, err := ReadCrateManifest(bytes.NewReader(crate))
+	if err != nil {
+		return nil, nil, NewCargoError(filePath, "", "", fmt.Sprintf("failed to read crate manifest: %v", err))
+	}
+	if err := ValidateMetadata(metadata); err != nil {
+		return nil, nil, NewCargoError(filePath, metadata.Name, metadata.Vers, err.Error())
+	}
+
+	return metadata, crate, nil
+}
+
+// UploadCrateFile validates a crate and publishes it to Nexus with retry logic.
+func (ci *CargoImporter) UploadCrateFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Inspect the crate manifest
+	metadata, crate, err := ci.InspectCrateFile(filePath)
+	if err != nil {
+		errorLogger("Rejected crate '%s': %v", filePath, err)
+		return fmt.Errorf("invalid crate: %w", err)
+	}
+	debugLogger("Parsed crate - Name: %s, Version: %s, Dependencies: %d", metadata.Name, metadata.Vers, len(metadata.Deps))
+
+	// Step 2: Encode the publish payload
+	body, err := BuildPublishBody(metadata, crate)
+	if err != nil {
+		errorLogger("Failed to build publish payload for crate '%s': %v", filePath, err)
+		return NewCargoError(filePath, metadata.Name, metadata.Vers, err.Error())
+	}
+
+	// Step 3: PUT the payload to the publish endpoint
+	targetURL := ci.BuildTargetURL()
+	debugLogger("Target URL: %s", targetURL)
+	return importer.SendWithRetry(ctx, ci.HTTPClient, filePath, retryAttempts, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPut, targetURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return ci.HTTPClient.TrackProgress(ctx, req), nil
+	}, debugLogger, errorLogger)
+}
@@ -0,0 +1,40 @@
+package cargo
+
+import (
+	"fmt"
+	"iscrie/core/importer"
+	"iscrie/utils"
+)
+
+// CargoError represents specific errors for Cargo repository.
+type CargoError struct {
+	importer.ImportError        // Use generic values
+	Name                 string `json:"name,omitempty"`
+	Version              string `json:"version,omitempty"`
+}
+
+// NewCargoError creates CargoError instances.
+func NewCargoError(filePath, name, version, errorMessage string) CargoError {
+	return CargoError{
+		ImportError: importer.ImportError{
+			FilePath:       filePath,
+			RepositoryType: "cargo",
+			Error:          errorMessage,
+		},
+		Name:    name,
+		Version: version,
+	}
+}
+
+func FormatCargoErrorMessage(e CargoError) string {
+	return fmt.Sprintf(
+		"Cargo Error - File: %s, Name: %s, Version: %s, Error: %s",
+		e.FilePath, e.Name, e.Version, e.ImportError.Error,
+	)
+}
+
+func (e CargoError) Error() string {
+	formattedMessage := FormatCargoErrorMessage(e)
+	utils.LogError(formattedMessage)
+	return formattedMessage
+}
@@ -0,0 +1,249 @@
+package cargo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iscrie/utils"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+var crateNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+// PublishDependency is a dependency entry of the Cargo publish API metadata.
+type PublishDependency struct {
+	Name               string   `json:"name"`
+	VersionReq         string   `json:"version_req"`
+	Features           []string `json:"features"`
+	Optional           bool     `json:"optional"`
+	DefaultFeatures    bool     `json:"default_features"`
+	Target             *string  `json:"target"`
+	Kind               string   `json:"kind"`
+	Registry           *string  `json:"registry"`
+	ExplicitNameInTOML *string  `json:"explicit_name_in_toml"`
+}
+
+// PublishMetadata is the JSON document sent ahead of the crate by `cargo publish`.
+type PublishMetadata struct {
+	Name          string                       `json:"name"`
+	Vers          string                       `json:"vers"`
+	Deps          []PublishDependency          `json:"deps"`
+	Features      map[string][]string          `json:"features"`
+	Authors       []string                     `json:"authors"`
+	Description   *string                      `json:"description"`
+	Documentation *string                      `json:"documentation"`
+	Homepage      *string                      `json:"homepage"`
+	Readme        *string                      `json:"readme"`
+	ReadmeFile    *string                      `json:"readme_file"`
+	Keywords      []string                     `json:"keywords"`
+	Categories    []string                     `json:"categories"`
+	License       *string                      `json:"license"`
+	LicenseFile   *string                      `json:"license_file"`
+	Repository    *string                      `json:"repository"`
+	Badges        map[string]map[string]string `json:"badges"`
+	Links         *string                      `json:"links"`
+	RustVersion   *string                      `json:"rust_version"`
+}
+
+// ReadCrateManifest extracts the normalized Cargo.toml at the root of a .crate archive
+// and builds the publish metadata from it.
+func ReadCrateManifest(r io.Reader) (*PublishMetadata, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, utils.LogAndReturnError("crate is not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, utils.LogAndReturnError("Cargo.toml not found in crate")
+		}
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to read crate archive: %w", err)
+		}
+
+		// Entries are prefixed with <name>-<version>/
+		dir, file := path.Split(path.Clean(hdr.Name))
+		if file != "Cargo.toml" || path.Dir(path.Clean(dir)) != "." {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to read Cargo.toml: %w", err)
+		}
+		metadata, err := ParseManifest(content)
+		if err != nil {
+			return nil, err
+		}
+		if expected := metadata.Name + "-" + metadata.Vers + "/"; dir != expected {
+			return nil, utils.LogAndReturnError("crate root directory '%s' does not match manifest '%s'", dir, expected)
+		}
+		return metadata, nil
+	}
+}
+
+// ParseManifest converts a normalized Cargo.toml into publish metadata.
+func ParseManifest(content []byte) (*PublishMetadata, error) {
+	var manifest map[string]interface{}
+	if err := toml.Unmarshal(content, &manifest); err != nil {
+		return nil, utils.LogAndReturnError("failed to parse Cargo.toml: %w", err)
+	}
+
+	pkg, _ := manifest["package"].(map[string]interface{})
+	if pkg == nil {
+		return nil, utils.LogAndReturnError("Cargo.toml has no [package] section")
+	}
+
+	metadata := &PublishMetadata{
+		Name:          stringValue(pkg, "name"),
+		Vers:          stringValue(pkg, "version"),
+		Deps:          []PublishDependency{},
+		Features:      map[string][]string{},
+		Authors:       stringList(pkg, "authors"),
+		Description:   optionalString(pkg, "description"),
+		Documentation: optionalString(pkg, "documentation"),
+		Homepage:      optionalString(pkg, "homepage"),
+		ReadmeFile:    optionalString(pkg, "readme"),
+		Keywords:      stringList(pkg, "keywords"),
+		Categories:    stringList(pkg, "categories"),
+		License:       optionalString(pkg, "license"),
+		LicenseFile:   optionalString(pkg, "license-file"),
+		Repository:    optionalString(pkg, "repository"),
+		Badges:        map[string]map[string]string{},
+		Links:         optionalString(pkg, "links"),
+		RustVersion:   optionalString(pkg, "rust-version"),
+	}
+
+	if features, ok := manifest["features"].(map[string]interface{}); ok {
+		for feature := range features {
+			metadata.Features[feature] = stringList(features, feature)
+		}
+	}
+
+	metadata.Deps = append(metadata.Deps, dependencies(manifest, nil)...)
+	if targets, ok := manifest["target"].(map[string]interface{}); ok {
+		for target, table := range targets {
+			if targetTable, ok := table.(map[string]interface{}); ok {
+				targetName := target
+				metadata.Deps = append(metadata.Deps, dependencies(targetTable, &targetName)...)
+			}
+		}
+	}
+	sort.Slice(metadata.Deps, func(i, j int) bool { return dependencyKey(metadata.Deps[i]) < dependencyKey(metadata.Deps[j]) })
+
+	return metadata, nil
+}
+
+// dependencies reads the [dependencies], [dev-dependencies] and [build-dependencies] tables.
+func dependencies(table map[string]interface{}, target *string) []PublishDependency {
+	kinds := map[string]string{
+		"dependencies":       "normal",
+		"dev-dependencies":   "dev",
+		"build-dependencies": "build",
+	}
+
+	var deps []PublishDependency
+	for section, kind := range kinds {
+		entries, ok := table[section].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, entry := range entries {
+			dep := PublishDependency{Name: name, Kind: kind, Target: target, DefaultFeatures: true, Features: []string{}}
+			switch value := entry.(type) {
+			case string:
+				dep.VersionReq = value
+			case map[string]interface{}:
+				dep.VersionReq = stringValue(value, "version")
+				dep.Features = stringList(value, "features")
+				dep.Optional, _ = value["optional"].(bool)
+				if defaultFeatures, ok := value["default-features"].(bool); ok {
+					dep.DefaultFeatures = defaultFeatures
+				}
+				dep.Registry = optionalString(value, "registry-index")
+				if renamed := stringValue(value, "package"); renamed != "" {
+					explicitName := name
+					dep.Name = renamed
+					dep.ExplicitNameInTOML = &explicitName
+				}
+			}
+			if dep.VersionReq == "" {
+				dep.VersionReq = "*"
+			}
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// ValidateMetadata checks the crate name and version.
+func ValidateMetadata(metadata *PublishMetadata) error {
+	if !crateNameRegex.MatchString(metadata.Name) {
+		return fmt.Errorf("invalid or missing crate name: %q", metadata.Name)
+	}
+	if metadata.Vers == "" {
+		return fmt.Errorf("missing crate version")
+	}
+	return nil
+}
+
+// BuildPublishBody encodes the publish API payload: the JSON metadata and the crate,
+// each preceded by its length as a 32-bit little-endian integer.
+func BuildPublishBody(metadata *PublishMetadata, crate []byte) ([]byte, error) {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode publish metadata: %w", err)
+	}
+
+	var body bytes.Buffer
+	body.Grow(8 + len(metadataJSON) + len(crate))
+	binary.Write(&body, binary.LittleEndian, uint32(len(metadataJSON)))
+	body.Write(metadataJSON)
+	binary.Write(&body, binary.LittleEndian, uint32(len(crate)))
+	body.Write(crate)
+	return body.Bytes(), nil
+}
+
+// dependencyKey orders dependencies deterministically despite TOML tables being unordered maps.
+func dependencyKey(dep PublishDependency) string {
+	key := dep.Name + "\x00" + dep.Kind
+	if dep.Target != nil {
+		key += "\x00" + *dep.Target
+	}
+	return key
+}
+
+func stringValue(table map[string]interface{}, key string) string {
+	value, _ := table[key].(string)
+	return value
+}
+
+func optionalString(table map[string]interface{}, key string) *string {
+	if value, ok := table[key].(string); ok {
+		return &value
+	}
+	return nil
+}
+
+func stringList(table map[string]interface{}, key string) []string {
+	values := []string{}
+	if list, ok := table[key].([]interface{}); ok {
+		for _, item := range list {
+			if value, ok := item.(string); ok {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
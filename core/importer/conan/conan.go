@@ -0,0 +1,147 @@
+package conan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iscrie/config"
+	"iscrie/core/importer"
+	"iscrie/network"
+	"iscrie/network/middleware"
+	"iscrie/utils"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// ConanImporter handles importing Conan recipes and packages into a Nexus conan hosted repository.
+type ConanImporter struct {
+	BaseURL      string
+	Repository   string
+	HTTPClient   *network.HTTPClientAdapter
+	RootPath     string
+	ForceReplace bool
+}
+
+// NewConanImporter creates a new ConanImporter instance.
+func NewConanImporter(baseURL, repository, rootPath string, httpClient *network.HTTPClient, forceReplace bool) *ConanImporter {
+	adapter := network.NewHTTPClientAdapter(httpClient, baseURL, repository, forceReplace)
+
+	return &ConanImporter{
+		BaseURL:      baseURL,
+		Repository:   repository,
+		HTTPClient:   adapter,
+		RootPath:     rootPath,
+		ForceReplace: forceReplace,
+	}
+}
+
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "conan",
+		Match:          IsConanFile,
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return NewConanImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace).UploadConanFile
+		},
+	})
+}
+
+// ResolveConanFile identifies the recipe reference and package of a file from its path.
+func (ci *ConanImporter) ResolveConanFile(filePath string) (*ConanFile, error) {
+	normalizedPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, NewConanError(filePath, nil, fmt.Sprintf("failed to normalize path: %v", err))
+	}
+	relativePath, err := filepath.Rel(ci.RootPath, normalizedPath)
+	if err != nil {
+		return nil, NewConanError(filePath, nil, fmt.Sprintf("failed to compute relative path: %v", err))
+	}
+
+	conanFile, err := ParseLayout(filepath.ToSlash(relativePath))
+	if err != nil {
+		return nil, NewConanError(filePath, nil, err.Error())
+	}
+	return conanFile, nil
+}
+
+// RequestUploadURL asks Nexus where to PUT the file, as `conan upload` does with the v1 API.
+func (ci *ConanImporter) RequestUploadURL(ctx context.Context, conanFile *ConanFile, size int64, retryAttempts int) (string, error) {
+	apiURL := fmt.Sprintf("%srepository/%s/%s", utils.NormalizeBaseURL(ci.BaseURL), ci.Repository, conanFile.UploadURLsPath())
+	payload, err := json.Marshal(map[string]int64{conanFile.FileName: size})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode upload_urls request: %w", err)
+	}
+
+	var uploadURL string
+	policy := ci.HTTPClient.HTTPClient.Retry
+	policy.Attempts = retryAttempts
+	_, err = policy.Run(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := ci.HTTPClient.Do(ctx, req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return middleware.NewStatusError(resp, "unexpected response status %d from %s", resp.StatusCode, apiURL)
+		}
+
+		var urls map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&urls); err != nil {
+			return fmt.Errorf("invalid upload_urls response: %w", err)
+		}
+		uploadURL = urls[conanFile.FileName]
+		if uploadURL == "" {
+			return fmt.Errorf("no upload URL returned for '%s'", conanFile.FileName)
+		}
+		return nil
+	})
+	return uploadURL, err
+}
+
+// UploadConanFile uploads a recipe or package file to Nexus with retry logic.
+func (ci *ConanImporter) UploadConanFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Identify the reference from the layout
+	conanFile, err := ci.ResolveConanFile(filePath)
+	if err != nil {
+		errorLogger("Rejected Conan file '%s': %v", filePath, err)
+		return fmt.Errorf("invalid Conan file: %w", err)
+	}
+	debugLogger("Parsed Conan file - Reference: %s, PackageID: %s, File: %s", conanFile.Reference, conanFile.PackageID, conanFile.FileName)
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		errorLogger("Failed to stat file '%s': %v", filePath, err)
+		return NewConanError(filePath, conanFile, fmt.Sprintf("failed to stat file: %v", err))
+	}
+
+	// Step 2: Obtain the upload URL (skipped in dry-run, the URL is only known to Nexus)
+	if ci.HTTPClient.HTTPClient.DryRun {
+		utils.LogInfo("[dry-run] PUT <upload URL from %srepository/%s/%s> <- %s", utils.NormalizeBaseURL(ci.BaseURL), ci.Repository, conanFile.UploadURLsPath(), filePath)
+		return nil
+	}
+	targetURL, err := ci.RequestUploadURL(ctx, conanFile, fileInfo.Size(), retryAttempts)
+	if err != nil {
+		errorLogger("Failed to obtain upload URL for file '%s': %v", filePath, err)
+		return NewConanError(filePath, conanFile, fmt.Sprintf("failed to obtain upload URL: %v", err))
+	}
+	debugLogger("Target URL: %s", targetURL)
+
+	// Step 3: Call `UploadFileToURLWithRetry` with both loggers
+	return importer.UploadFileToURLWithRetry(ctx, ci.HTTPClient, targetURL, filePath, retryAttempts, debugLogger, errorLogger)
+}
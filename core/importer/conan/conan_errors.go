@@ -0,0 +1,43 @@
+package conan
+
+import (
+	"fmt"
+	"iscrie/core/importer"
+	"iscrie/utils"
+)
+
+// ConanError represents specific errors for Conan repository.
+type ConanError struct {
+	importer.ImportError        // Use generic values
+	Reference            string `json:"reference,omitempty"`
+	PackageID            string `json:"package_id,omitempty"`
+}
+
+// NewConanError creates ConanError instances.
+func NewConanError(filePath string, file *ConanFile, errorMessage string) ConanError {
+	conanError := ConanError{
+		ImportError: importer.ImportError{
+			FilePath:       filePath,
+			RepositoryType: "conan",
+			Error:          errorMessage,
+		},
+	}
+	if file != nil {
+		conanError.Reference = file.Reference.String()
+		conanError.PackageID = file.PackageID
+	}
+	return conanError
+}
+
+func FormatConanErrorMessage(e ConanError) string {
+	return fmt.Sprintf(
+		"Conan Error - File: %s, Reference: %s, PackageID: %s, Error: %s",
+		e.FilePath, e.Reference, e.PackageID, e.ImportError.Error,
+	)
+}
+
+func (e ConanError) Error() string {
+	formattedMessage := FormatConanErrorMessage(e)
+	utils.LogError(formattedMessage)
+	return formattedMessage
+}
@@ -0,0 +1,124 @@
+package conan
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	referenceFieldRegex = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_+.-]{1,50}$`)
+	packageIDRegex      = regexp.MustCompile(`^[a-f0-9]{40}$`)
+)
+
+// Files uploaded by `conan upload` for a recipe export and for a binary package.
+var (
+	exportFiles  = []string{"conanfile.py", "conanmanifest.txt", "conan_export.tgz", "conan_sources.tgz"}
+	packageFiles = []string{"conaninfo.txt", "conanmanifest.txt", "conan_package.tgz"}
+)
+
+// Reference identifies a Conan recipe: name/version@user/channel.
+type Reference struct {
+	Name    string
+	Version string
+	User    string
+	Channel string
+}
+
+// String formats the reference the way Conan displays it.
+func (r Reference) String() string {
+	return fmt.Sprintf("%s/%s@%s/%s", r.Name, r.Version, r.User, r.Channel)
+}
+
+// ConanFile is a recipe or package file located in the Conan layout.
+type ConanFile struct {
+	Reference Reference
+	PackageID string // Empty for recipe export files
+	FileName  string
+}
+
+// IsPackage reports whether the file belongs to a binary package rather than the recipe export.
+func (cf ConanFile) IsPackage() bool {
+	return cf.PackageID != ""
+}
+
+// IsConanFile reports whether a file sits in an export/ or package/<id>/ folder with a known name.
+func IsConanFile(filePath string) bool {
+	fileName := filepath.Base(filePath)
+	parent := filepath.Dir(filePath)
+
+	if filepath.Base(parent) == "export" {
+		return slices.Contains(exportFiles, fileName)
+	}
+	if filepath.Base(filepath.Dir(parent)) == "package" {
+		return slices.Contains(packageFiles, fileName)
+	}
+	return false
+}
+
+// ParseLayout parses a path relative to the root laid out as
+// <name>/<version>/<user>/<channel>/export/<file> or <name>/<version>/<user>/<channel>/package/<id>/<file>.
+func ParseLayout(relativePath string) (*ConanFile, error) {
+	segments := strings.Split(path.Clean(relativePath), "/")
+
+	var conanFile ConanFile
+	var referenceSegments []string
+	switch {
+	case len(segments) >= 6 && segments[len(segments)-2] == "export":
+		referenceSegments = segments[len(segments)-6 : len(segments)-2]
+		conanFile.FileName = segments[len(segments)-1]
+		if !slices.Contains(exportFiles, conanFile.FileName) {
+			return nil, fmt.Errorf("unexpected recipe file '%s' (expected one of %s)", conanFile.FileName, strings.Join(exportFiles, ", "))
+		}
+	case len(segments) >= 7 && segments[len(segments)-3] == "package":
+		referenceSegments = segments[len(segments)-7 : len(segments)-3]
+		conanFile.PackageID = segments[len(segments)-2]
+		conanFile.FileName = segments[len(segments)-1]
+		if !slices.Contains(packageFiles, conanFile.FileName) {
+			return nil, fmt.Errorf("unexpected package file '%s' (expected one of %s)", conanFile.FileName, strings.Join(packageFiles, ", "))
+		}
+		if !packageIDRegex.MatchString(conanFile.PackageID) {
+			return nil, fmt.Errorf("invalid package id '%s'", conanFile.PackageID)
+		}
+	default:
+		return nil, fmt.Errorf("path '%s' does not follow the <name>/<version>/<user>/<channel>/{export,package/<id>}/<file> layout", relativePath)
+	}
+
+	conanFile.Reference = Reference{
+		Name:    referenceSegments[0],
+		Version: referenceSegments[1],
+		User:    referenceSegments[2],
+		Channel: referenceSegments[3],
+	}
+	if err := ValidateReference(conanFile.Reference); err != nil {
+		return nil, err
+	}
+	return &conanFile, nil
+}
+
+// ValidateReference checks every field of a reference. "_" stands for no user or channel.
+func ValidateReference(r Reference) error {
+	fields := map[string]string{"name": r.Name, "version": r.Version, "user": r.User, "channel": r.Channel}
+	for field, value := range fields {
+		if (field == "user" || field == "channel") && value == "_" {
+			continue
+		}
+		if !referenceFieldRegex.MatchString(value) {
+			return fmt.Errorf("invalid reference %s '%s'", field, value)
+		}
+	}
+	return nil
+}
+
+// UploadURLsPath returns the API path that hands out upload URLs for the file.
+func (cf ConanFile) UploadURLsPath() string {
+	r := cf.Reference
+	base := fmt.Sprintf("v1/conans/%s/%s/%s/%s", r.Name, r.Version, r.User, r.Channel)
+	if cf.IsPackage() {
+		return fmt.Sprintf("%s/packages/%s/upload_urls", base, cf.PackageID)
+	}
+	return base + "/upload_urls"
+}
@@ -0,0 +1,105 @@
+package conda
+
+import (
+	"context"
+	"fmt"
+	"iscrie/config"
+	"iscrie/core/importer"
+	"iscrie/network"
+	"iscrie/utils"
+	"path/filepath"
+)
+
+// CondaImporter handles importing conda packages into a Nexus conda hosted repository.
+type CondaImporter struct {
+	BaseURL      string
+	Repository   string
+	HTTPClient   *network.HTTPClientAdapter
+	RootPath     string
+	ForceReplace bool
+}
+
+// NewCondaImporter creates a new CondaImporter instance.
+func NewCondaImporter(baseURL, repository, rootPath string, httpClient *network.HTTPClient, forceReplace bool) *CondaImporter {
+	adapter := network.NewHTTPClientAdapter(httpClient, baseURL, repository, forceReplace)
+
+	return &CondaImporter{
+		BaseURL:      baseURL,
+		Repository:   repository,
+		HTTPClient:   adapter,
+		RootPath:     rootPath,
+		ForceReplace: forceReplace,
+	}
+}
+
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "conda",
+		Match: func(filePath string) bool {
+			return PackageExtension(filepath.Base(filePath)) != ""
+		},
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return NewCondaImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace).UploadCondaFile
+		},
+		NewLocator: func(cfg *config.Config, httpClient *network.HTTPClient) importer.LocateFunc {
+			return NewCondaImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace).LocateCondaFile
+		},
+	})
+}
+
+// InspectCondaPackage reads and validates the info/index.json of a conda package.
+func (ci *CondaImporter) InspectCondaPackage(filePath string) (*PackageIndex, error) {
+	fileName := filepath.Base(filePath)
+	if PackageExtension(fileName) == "" {
+		return nil, NewCondaError(filePath, nil, "not a conda package (.tar.bz2 or .conda)")
+	}
+
+	index, err := ReadPackageIndex(filePath)
+	if err != nil {
+		return nil, NewCondaError(filePath, nil, fmt.Sprintf("failed to read info/index.json: %v", err))
+	}
+	if err := ValidateIndex(index, fileName); err != nil {
+		return nil, NewCondaError(filePath, index, err.Error())
+	}
+	return index, nil
+}
+
+// BuildTargetURL constructs the target URL of a conda package under its platform subdir.
+func (ci *CondaImporter) BuildTargetURL(filePath string, index *PackageIndex) string {
+	return fmt.Sprintf("%srepository/%s/%s/%s", utils.NormalizeBaseURL(ci.BaseURL), ci.Repository, index.Subdir, filepath.Base(filePath))
+}
+
+// LocateCondaFile returns the target URL of a conda package, read from its info/index.json.
+func (ci *CondaImporter) LocateCondaFile(filePath string) (string, error) {
+	index, err := ci.InspectCondaPackage(filePath)
+	if err != nil {
+		return "", err
+	}
+	return ci.BuildTargetURL(filePath, index), nil
+}
+
+// UploadCondaFile validates a conda package and uploads it to Nexus with retry logic.
+func (ci *CondaImporter) UploadCondaFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Inspect the package index
+	index, err := ci.InspectCondaPackage(filePath)
+	if err != nil {
+		errorLogger("Rejected conda package '%s': %v", filePath, err)
+		return fmt.Errorf("invalid conda package: %w", err)
+	}
+	debugLogger("Parsed package - Name: %s, Version: %s, Build: %s, Subdir: %s", index.Name, index.Version, index.Build, index.Subdir)
+
+	// Step 2: Build the target URL
+	targetURL := ci.BuildTargetURL(filePath, index)
+	debugLogger("Target URL: %s", targetURL)
+
+	// Step 3: Call `UploadFileWithRetry` with both loggers
+	return importer.UploadFileWithRetry(ctx, ci.HTTPClient, targetURL, filePath, retryAttempts, debugLogger, errorLogger)
+}
@@ -0,0 +1,47 @@
+package conda
+
+import (
+	"fmt"
+	"iscrie/core/importer"
+	"iscrie/utils"
+)
+
+// CondaError represents specific errors for conda repository.
+type CondaError struct {
+	importer.ImportError        // Use generic values
+	Name                 string `json:"name,omitempty"`
+	Version              string `json:"version,omitempty"`
+	Build                string `json:"build,omitempty"`
+	Subdir               string `json:"subdir,omitempty"`
+}
+
+// NewCondaError creates CondaError instances.
+func NewCondaError(filePath string, index *PackageIndex, errorMessage string) CondaError {
+	condaError := CondaError{
+		ImportError: importer.ImportError{
+			FilePath:       filePath,
+			RepositoryType: "conda",
+			Error:          errorMessage,
+		},
+	}
+	if index != nil {
+		condaError.Name = index.Name
+		condaError.Version = index.Version
+		condaError.Build = index.Build
+		condaError.Subdir = index.Subdir
+	}
+	return condaError
+}
+
+func FormatCondaErrorMessage(e CondaError) string {
+	return fmt.Sprintf(
+		"Conda Error - File: %s, Name: %s, Version: %s, Build: %s, Subdir: %s, Error: %s",
+		e.FilePath, e.Name, e.Version, e.Build, e.Subdir, e.ImportError.Error,
+	)
+}
+
+func (e CondaError) Error() string {
+	formattedMessage := FormatCondaErrorMessage(e)
+	utils.LogError(formattedMessage)
+	return formattedMessage
+}
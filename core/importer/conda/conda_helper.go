@@ -0,0 +1,131 @@
+package conda
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iscrie/utils"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Package archive extensions: the legacy tarball and the zip-based v2 format.
+const (
+	tarballExtension = ".tar.bz2"
+	condaExtension   = ".conda"
+)
+
+var subdirRegex = regexp.MustCompile(`^(noarch|[a-z0-9]+-[a-z0-9_]+)$`)
+
+// PackageIndex holds the info/index.json fields used to place a conda package.
+type PackageIndex struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Build   string `json:"build"`
+	Subdir  string `json:"subdir"`
+}
+
+// PackageExtension returns the archive extension of a conda package file, or "" if unsupported.
+func PackageExtension(fileName string) string {
+	for _, extension := range []string{tarballExtension, condaExtension} {
+		if strings.HasSuffix(fileName, extension) {
+			return extension
+		}
+	}
+	return ""
+}
+
+// ReadPackageIndex extracts and decodes info/index.json from a .tar.bz2 or .conda package.
+func ReadPackageIndex(filePath string) (*PackageIndex, error) {
+	var content []byte
+	var err error
+	if PackageExtension(filePath) == condaExtension {
+		content, err = readCondaIndex(filePath)
+	} else {
+		content, err = readTarballIndex(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var index PackageIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, utils.LogAndReturnError("invalid info/index.json: %w", err)
+	}
+	return &index, nil
+}
+
+func readTarballIndex(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readIndexFromTar(bzip2.NewReader(file), filePath)
+}
+
+// readCondaIndex opens the info-*.tar.zst member of a .conda package, which holds the metadata.
+func readCondaIndex(filePath string) ([]byte, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, utils.LogAndReturnError("package is not a zip archive: %w", err)
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		if !strings.HasPrefix(entry.Name, "info-") || !strings.HasSuffix(entry.Name, ".tar.zst") {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		zr, err := zstd.NewReader(rc)
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to open %s: %w", entry.Name, err)
+		}
+		defer zr.Close()
+		return readIndexFromTar(zr, entry.Name)
+	}
+	return nil, utils.LogAndReturnError("info-*.tar.zst not found in package")
+}
+
+func readIndexFromTar(r io.Reader, name string) ([]byte, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, utils.LogAndReturnError("info/index.json not found in %s", name)
+		}
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to read %s: %w", name, err)
+		}
+		if path.Clean(hdr.Name) == "info/index.json" {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// ValidateIndex checks the index fields against the file name <name>-<version>-<build><ext>.
+func ValidateIndex(index *PackageIndex, fileName string) error {
+	if index.Name == "" || index.Version == "" || index.Build == "" {
+		return fmt.Errorf("info/index.json is missing name, version or build")
+	}
+	if !subdirRegex.MatchString(index.Subdir) {
+		return fmt.Errorf("invalid or missing subdir: %q", index.Subdir)
+	}
+	expected := fmt.Sprintf("%s-%s-%s%s", index.Name, index.Version, index.Build, PackageExtension(fileName))
+	if fileName != expected {
+		return fmt.Errorf("file name does not match info/index.json, expected '%s'", expected)
+	}
+	return nil
+}
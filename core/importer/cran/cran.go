@@ -0,0 +1,114 @@
+package cran
+
+import (
+	"context"
+	"fmt"
+	"iscrie/config"
+	"iscrie/core/importer"
+	"iscrie/network"
+	"iscrie/utils"
+	"path/filepath"
+)
+
+// RImporter handles importing R packages into a Nexus r hosted repository.
+type RImporter struct {
+	BaseURL      string
+	Repository   string
+	HTTPClient   *network.HTTPClientAdapter
+	RootPath     string
+	ForceReplace bool
+}
+
+// NewRImporter creates a new RImporter instance.
+func NewRImporter(baseURL, repository, rootPath string, httpClient *network.HTTPClient, forceReplace bool) *RImporter {
+	adapter := network.NewHTTPClientAdapter(httpClient, baseURL, repository, forceReplace)
+
+	return &RImporter{
+		BaseURL:      baseURL,
+		Repository:   repository,
+		HTTPClient:   adapter,
+		RootPath:     rootPath,
+		ForceReplace: forceReplace,
+	}
+}
+
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "r",
+		Match: func(filePath string) bool {
+			return PackageExtension(filepath.Base(filePath)) != ""
+		},
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return NewRImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace).UploadRFile
+		},
+		NewLocator: func(cfg *config.Config, httpClient *network.HTTPClient) importer.LocateFunc {
+			return NewRImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace).LocateRFile
+		},
+	})
+}
+
+// InspectRPackage reads and validates the DESCRIPTION of an R package.
+func (ri *RImporter) InspectRPackage(filePath string) (*PackageInfo, error) {
+	fileName := filepath.Base(filePath)
+	if PackageExtension(fileName) == "" {
+		return nil, NewRError(filePath, nil, "not an R package (.tar.gz, .zip or .tgz)")
+	}
+
+	info, err := ReadDescription(filePath)
+	if err != nil {
+		return nil, NewRError(filePath, nil, fmt.Sprintf("failed to read DESCRIPTION: %v", err))
+	}
+	if err := ValidatePackage(info, fileName); err != nil {
+		return nil, NewRError(filePath, info, err.Error())
+	}
+	return info, nil
+}
+
+// BuildTargetURL constructs the target URL of an R package in the CRAN layout.
+func (ri *RImporter) BuildTargetURL(filePath string, info *PackageInfo) (string, error) {
+	fileName := filepath.Base(filePath)
+	directory, err := RepositoryPath(info, fileName)
+	if err != nil {
+		return "", NewRError(filePath, info, err.Error())
+	}
+	return fmt.Sprintf("%srepository/%s/%s/%s", utils.NormalizeBaseURL(ri.BaseURL), ri.Repository, directory, fileName), nil
+}
+
+// LocateRFile returns the target URL of an R package, read from its DESCRIPTION.
+func (ri *RImporter) LocateRFile(filePath string) (string, error) {
+	info, err := ri.InspectRPackage(filePath)
+	if err != nil {
+		return "", err
+	}
+	return ri.BuildTargetURL(filePath, info)
+}
+
+// UploadRFile validates an R package and uploads it to Nexus with retry logic.
+func (ri *RImporter) UploadRFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Inspect the DESCRIPTION file
+	info, err := ri.InspectRPackage(filePath)
+	if err != nil {
+		errorLogger("Rejected R package '%s': %v", filePath, err)
+		return fmt.Errorf("invalid R package: %w", err)
+	}
+	debugLogger("Parsed package - Package: %s, Version: %s, Built: %s", info.Package, info.Version, info.Built)
+
+	// Step 2: Build the target URL
+	targetURL, err := ri.BuildTargetURL(filePath, info)
+	if err != nil {
+		errorLogger("Failed to build target URL for file '%s': %v", filePath, err)
+		return fmt.Errorf("failed to build target URL: %w", err)
+	}
+	debugLogger("Target URL: %s", targetURL)
+
+	// Step 3: Call `UploadFileWithRetry` with both loggers
+	return importer.UploadFileWithRetry(ctx, ri.HTTPClient, targetURL, filePath, retryAttempts, debugLogger, errorLogger)
+}
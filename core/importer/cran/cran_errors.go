@@ -0,0 +1,45 @@
+package cran
+
+import (
+	"fmt"
+	"iscrie/core/importer"
+	"iscrie/utils"
+)
+
+// RError represents specific errors for R repository.
+type RError struct {
+	importer.ImportError        // Use generic values
+	Package              string `json:"package,omitempty"`
+	Version              string `json:"version,omitempty"`
+	Built                string `json:"built,omitempty"`
+}
+
+// NewRError creates RError instances.
+func NewRError(filePath string, info *PackageInfo, errorMessage string) RError {
+	rError := RError{
+		ImportError: importer.ImportError{
+			FilePath:       filePath,
+			RepositoryType: "r",
+			Error:          errorMessage,
+		},
+	}
+	if info != nil {
+		rError.Package = info.Package
+		rError.Version = info.Version
+		rError.Built = info.Built
+	}
+	return rError
+}
+
+func FormatRErrorMessage(e RError) string {
+	return fmt.Sprintf(
+		"R Error - File: %s, Package: %s, Version: %s, Built: %s, Error: %s",
+		e.FilePath, e.Package, e.Version, e.Built, e.ImportError.Error,
+	)
+}
+
+func (e RError) Error() string {
+	formattedMessage := FormatRErrorMessage(e)
+	utils.LogError(formattedMessage)
+	return formattedMessage
+}
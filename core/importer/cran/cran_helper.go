@@ -0,0 +1,175 @@
+package cran
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"iscrie/core/importer"
+	"iscrie/utils"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	packageNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9.]*[A-Za-z0-9]$`)
+	versionRegex     = regexp.MustCompile(`^[0-9]+([.-][0-9]+)+$`)
+	rVersionRegex    = regexp.MustCompile(`^R ([0-9]+\.[0-9]+)`)
+)
+
+// Package archive extensions: sources, Windows binaries and macOS binaries.
+const (
+	sourceExtension  = ".tar.gz"
+	windowsExtension = ".zip"
+	macosExtension   = ".tgz"
+)
+
+// PackageInfo holds the DESCRIPTION fields used to place an R package.
+type PackageInfo struct {
+	Package string
+	Version string
+	Built   string // Empty for source packages
+}
+
+// PackageExtension returns the archive extension of an R package file, or "" if unsupported.
+func PackageExtension(fileName string) string {
+	for _, extension := range []string{sourceExtension, windowsExtension, macosExtension} {
+		if strings.HasSuffix(fileName, extension) {
+			return extension
+		}
+	}
+	return ""
+}
+
+// ReadDescription extracts and parses the <package>/DESCRIPTION file of an R package archive.
+func ReadDescription(filePath string) (*PackageInfo, error) {
+	var content []byte
+	var err error
+	if PackageExtension(filePath) == windowsExtension {
+		content, err = readZipDescription(filePath)
+	} else {
+		content, err = readTarDescription(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := importer.ParseDCF(content)
+	if err != nil {
+		return nil, err
+	}
+	return &PackageInfo{
+		Package: fields["Package"],
+		Version: fields["Version"],
+		Built:   fields["Built"],
+	}, nil
+}
+
+// isDescription reports whether an archive entry is the top-level <package>/DESCRIPTION.
+func isDescription(name string) bool {
+	dir, file := path.Split(path.Clean(name))
+	return file == "DESCRIPTION" && dir != "" && !strings.Contains(strings.TrimSuffix(dir, "/"), "/")
+}
+
+func readTarDescription(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, utils.LogAndReturnError("package is not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, utils.LogAndReturnError("DESCRIPTION not found in package")
+		}
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to read package archive: %w", err)
+		}
+		if isDescription(hdr.Name) {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+func readZipDescription(filePath string) ([]byte, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, utils.LogAndReturnError("package is not a zip archive: %w", err)
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		if !isDescription(entry.Name) {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, utils.LogAndReturnError("DESCRIPTION not found in package")
+}
+
+// ValidatePackage checks the DESCRIPTION fields against the archive file name <package>_<version><ext>.
+func ValidatePackage(info *PackageInfo, fileName string) error {
+	if !packageNameRegex.MatchString(info.Package) {
+		return fmt.Errorf("invalid or missing Package field: %q", info.Package)
+	}
+	if !versionRegex.MatchString(info.Version) {
+		return fmt.Errorf("invalid or missing Version field: %q", info.Version)
+	}
+	expected := info.Package + "_" + info.Version + PackageExtension(fileName)
+	if fileName != expected {
+		return fmt.Errorf("file name does not match DESCRIPTION, expected '%s'", expected)
+	}
+	return nil
+}
+
+// RepositoryPath returns the CRAN directory of a package:
+// src/contrib for sources, bin/<platform>/contrib/<R major.minor> for binaries.
+// The Built field, "R 4.3.1; x86_64-w64-mingw32; <date>; windows", identifies binaries.
+func RepositoryPath(info *PackageInfo, fileName string) (string, error) {
+	extension := PackageExtension(fileName)
+	if info.Built == "" {
+		if extension != sourceExtension {
+			return "", fmt.Errorf("source packages must be %s archives", sourceExtension)
+		}
+		return "src/contrib", nil
+	}
+
+	matches := rVersionRegex.FindStringSubmatch(info.Built)
+	if matches == nil {
+		return "", fmt.Errorf("cannot read R version from Built field: %q", info.Built)
+	}
+	rVersion := matches[1]
+	builtFields := strings.Split(info.Built, ";")
+	builtOS := strings.TrimSpace(builtFields[len(builtFields)-1])
+
+	switch extension {
+	case windowsExtension:
+		if builtOS != "windows" {
+			return "", fmt.Errorf("%s binary was built for '%s', expected 'windows'", windowsExtension, builtOS)
+		}
+		return fmt.Sprintf("bin/windows/contrib/%s", rVersion), nil
+	case macosExtension:
+		if builtOS != "unix" {
+			return "", fmt.Errorf("%s binary was built for '%s', expected 'unix'", macosExtension, builtOS)
+		}
+		return fmt.Sprintf("bin/macosx/contrib/%s", rVersion), nil
+	default:
+		return "", fmt.Errorf("binary packages must be Windows (%s) or macOS (%s) archives", windowsExtension, macosExtension)
+	}
+}
@@ -0,0 +1,46 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"iscrie/utils"
+	"strings"
+)
+
+// ParseDCF parses the first paragraph of a DCF (deb822) document,
+// the format of Debian control files and R DESCRIPTION files.
+// Continuation lines are appended to the previous field.
+func ParseDCF(content []byte) (map[string]string, error) {
+	fields := map[string]string{}
+	var current string
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(fields) > 0 {
+				break // Only the first paragraph is read
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if current == "" {
+				return nil, utils.LogAndReturnError("unexpected continuation line in DCF file: %q", line)
+			}
+			fields[current] += "\n" + strings.TrimSpace(line)
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, utils.LogAndReturnError("malformed DCF line: %q", line)
+		}
+		current = strings.TrimSpace(key)
+		fields[current] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, utils.LogAndReturnError("failed to scan DCF file: %w", err)
+	}
+
+	return fields, nil
+}
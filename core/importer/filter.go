@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"iscrie/config"
+	"iscrie/utils"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IgnoreFileName is the optional file of exclude patterns read from the root path.
+const IgnoreFileName = ".iscrieignore"
+
+// Reasons a file is filtered out of the walk, as reported in the summary.
+const (
+	SkipExcluded    = "excluded"
+	SkipNotIncluded = "not included"
+	SkipTooSmall    = "smaller than min_size"
+	SkipTooLarge    = "larger than max_size"
+	SkipNotModified = "not modified since modified_since"
+)
+
+// SkipReasons lists the filter reasons in reporting order.
+var SkipReasons = []string{SkipExcluded, SkipNotIncluded, SkipTooSmall, SkipTooLarge, SkipNotModified}
+
+// Filter decides which files of the walk are processed, from the [general] filters and the ignore file.
+type Filter struct {
+	RootPath      string
+	Include       []*utils.IgnorePattern
+	Exclude       []*utils.IgnorePattern // general.exclude, then the ignore file: the last match wins
+	MinSize       int64
+	MaxSize       int64
+	ModifiedSince time.Time
+}
+
+// NewFilter builds the filter of the walk and reads the ignore file, if present.
+func NewFilter(cfg *config.Config) (*Filter, error) {
+	filter := &Filter{
+		RootPath: cfg.General.RootPath,
+		MinSize:  cfg.General.MinSize,
+		MaxSize:  cfg.General.MaxSize,
+	}
+
+	var err error
+	if filter.Include, err = parsePatterns(cfg.General.Include); err != nil {
+		return nil, err
+	}
+	if filter.Exclude, err = parsePatterns(cfg.General.Exclude); err != nil {
+		return nil, err
+	}
+	if cfg.General.ModifiedSince != "" {
+		if filter.ModifiedSince, err = config.ParseModifiedSince(cfg.General.ModifiedSince); err != nil {
+			return nil, err
+		}
+	}
+
+	// An archive root has no ignore file
+	if info, err := os.Stat(cfg.General.RootPath); err == nil && !info.IsDir() {
+		return filter, nil
+	}
+	ignorePatterns, err := readIgnoreFile(filepath.Join(cfg.General.RootPath, IgnoreFileName))
+	if err != nil {
+		return nil, err
+	}
+	filter.Exclude = append(filter.Exclude, ignorePatterns...)
+	return filter, nil
+}
+
+func parsePatterns(lines []string) ([]*utils.IgnorePattern, error) {
+	var patterns []*utils.IgnorePattern
+	for _, line := range lines {
+		pattern, err := utils.ParseIgnorePattern(line)
+		if err != nil {
+			return nil, utils.LogAndReturnError("invalid pattern '%s': %w", line, err)
+		}
+		if pattern != nil {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, nil
+}
+
+func readIgnoreFile(ignorePath string) ([]*utils.IgnorePattern, error) {
+	file, err := os.Open(ignorePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, utils.LogAndReturnError("failed to open %s: %w", ignorePath, err)
+	}
+	defer file.Close()
+
+	var patterns []*utils.IgnorePattern
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		pattern, err := utils.ParseIgnorePattern(scanner.Text())
+		if err != nil {
+			return nil, utils.LogAndReturnError("%s:%d: invalid pattern: %w", ignorePath, lineNumber, err)
+		}
+		if pattern != nil {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, utils.LogAndReturnError("failed to read %s: %w", ignorePath, err)
+	}
+	utils.LogDebug("Loaded %d patterns from %s", len(patterns), ignorePath)
+	return patterns, nil
+}
+
+// relativePath returns the slash-separated path relative to RootPath.
+func (f *Filter) relativePath(filePath string) string {
+	relativePath, err := filepath.Rel(f.RootPath, filePath)
+	if err != nil {
+		return filepath.ToSlash(filePath)
+	}
+	return filepath.ToSlash(relativePath)
+}
+
+// SkipDir reports whether a directory is excluded, so that the walk does not enter it.
+func (f *Filter) SkipDir(dirPath string) bool {
+	relativePath := f.relativePath(dirPath)
+	if relativePath == "." {
+		return false
+	}
+	return utils.MatchIgnorePatterns(f.Exclude, relativePath, true)
+}
+
+// Skip returns why a file is filtered out, or "" if it must be processed.
+func (f *Filter) Skip(filePath string, info fs.FileInfo) string {
+	relativePath := f.relativePath(filePath)
+
+	switch {
+	case relativePath == IgnoreFileName:
+		return SkipExcluded
+	case utils.MatchIgnorePatterns(f.Exclude, relativePath, false):
+		return SkipExcluded
+	case len(f.Include) > 0 && !utils.MatchIgnorePatterns(f.Include, relativePath, false):
+		return SkipNotIncluded
+	case f.MinSize > 0 && info.Size() < f.MinSize:
+		return SkipTooSmall
+	case f.MaxSize > 0 && info.Size() > f.MaxSize:
+		return SkipTooLarge
+	case !f.ModifiedSince.IsZero() && info.ModTime().Before(f.ModifiedSince):
+		return SkipNotModified
+	}
+	return ""
+}
@@ -0,0 +1,16 @@
+// Package formats registers every importer shipped with Iscrie.
+// Import it for its side effects before loading the configuration.
+package formats
+
+import (
+	_ "iscrie/core/importer/apt"
+	_ "iscrie/core/importer/cargo"
+	_ "iscrie/core/importer/conan"
+	_ "iscrie/core/importer/conda"
+	_ "iscrie/core/importer/cran"
+	_ "iscrie/core/importer/gomodule"
+	_ "iscrie/core/importer/maven2"
+	_ "iscrie/core/importer/raw"
+	_ "iscrie/core/importer/rubygems"
+	_ "iscrie/core/importer/yum"
+)
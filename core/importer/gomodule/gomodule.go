@@ -0,0 +1,212 @@
+package gomodule
+
+import (
+	"context"
+	"fmt"
+	"iscrie/config"
+	"iscrie/core/importer"
+	"iscrie/network"
+	"iscrie/utils"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// GoModuleImporter handles importing Go modules into Nexus using the GOPROXY layout.
+type GoModuleImporter struct {
+	BaseURL      string
+	Repository   string
+	HTTPClient   *network.HTTPClientAdapter
+	RootPath     string
+	ForceReplace bool
+	TargetPrefix string // GOPROXY root inside the repository, empty for the repository root
+}
+
+// NewGoModuleImporter creates a new GoModuleImporter instance.
+func NewGoModuleImporter(baseURL, repository, rootPath string, httpClient *network.HTTPClient, forceReplace bool) *GoModuleImporter {
+	adapter := network.NewHTTPClientAdapter(httpClient, baseURL, repository, forceReplace)
+
+	return &GoModuleImporter{
+		BaseURL:      baseURL,
+		Repository:   repository,
+		HTTPClient:   adapter,
+		RootPath:     rootPath,
+		ForceReplace: forceReplace,
+	}
+}
+
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "go",
+		Match:          IsModuleFile,
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			goModuleImporter := NewGoModuleImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace)
+			goModuleImporter.TargetPrefix = cfg.Nexus.TargetPrefix
+			return goModuleImporter.UploadGoModuleFile
+		},
+		NewLocator: func(cfg *config.Config, httpClient *network.HTTPClient) importer.LocateFunc {
+			goModuleImporter := NewGoModuleImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace)
+			goModuleImporter.TargetPrefix = cfg.Nexus.TargetPrefix
+			return goModuleImporter.LocateGoModuleFile
+		},
+		TargetPrefix: true,
+	})
+}
+
+// relativePath returns the slash-separated path of a file relative to RootPath.
+func (gi *GoModuleImporter) relativePath(filePath string) (string, error) {
+	normalizedPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+	relativePath, err := filepath.Rel(gi.RootPath, normalizedPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(relativePath), nil
+}
+
+// ResolveModuleFile identifies the module, version and kind of a file and validates its content.
+// Files inside a GOMODCACHE/cache/download tree are identified from their path; loose files
+// from the zip prefix, the go.mod module directive, or the .info Version field.
+func (gi *GoModuleImporter) ResolveModuleFile(filePath string) (*ModuleFile, error) {
+	relativePath, err := gi.relativePath(filePath)
+	if err != nil {
+		return nil, NewGoModuleError(filePath, "", "", fmt.Sprintf("failed to compute relative path: %v", err))
+	}
+
+	var mf *ModuleFile
+	if strings.Contains(relativePath, "/@v/") {
+		mf, err = ParseCachePath(relativePath)
+	} else {
+		mf, err = resolveLooseFile(filePath)
+	}
+	if err != nil {
+		return nil, NewGoModuleError(filePath, "", "", err.Error())
+	}
+
+	if err := validateContent(filePath, mf); err != nil {
+		return nil, NewGoModuleError(filePath, mf.ModulePath, mf.Version, err.Error())
+	}
+	return mf, nil
+}
+
+// resolveLooseFile identifies a module file that is not laid out in GOPROXY form.
+func resolveLooseFile(filePath string) (*ModuleFile, error) {
+	kind := filepath.Ext(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), kind)
+
+	switch kind {
+	case KindZip:
+		m, err := ReadZipModule(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return &ModuleFile{ModulePath: m.Path, Version: m.Version, Kind: KindZip}, nil
+
+	case KindMod:
+		modulePath, err := ReadModulePath(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if err := module.Check(modulePath, baseName); err != nil {
+			return nil, fmt.Errorf("loose .mod files must be named <version>.mod: %w", err)
+		}
+		return &ModuleFile{ModulePath: modulePath, Version: baseName, Kind: KindMod}, nil
+
+	case KindInfo:
+		version, err := ReadInfoVersion(filePath)
+		if err != nil {
+			return nil, err
+		}
+		// The module path comes from the sibling .mod or .zip of the same version
+		siblingBase := strings.TrimSuffix(filePath, KindInfo)
+		if modulePath, err := ReadModulePath(siblingBase + KindMod); err == nil {
+			return &ModuleFile{ModulePath: modulePath, Version: version, Kind: KindInfo}, nil
+		}
+		if m, err := ReadZipModule(siblingBase + KindZip); err == nil {
+			return &ModuleFile{ModulePath: m.Path, Version: version, Kind: KindInfo}, nil
+		}
+		return nil, fmt.Errorf("cannot determine module path: no sibling %s%s or %s%s", baseName, KindMod, baseName, KindZip)
+
+	default:
+		return nil, fmt.Errorf("unsupported file type '%s': expected .info, .mod or .zip", kind)
+	}
+}
+
+// validateContent checks that the file content matches the module and version it is published as.
+func validateContent(filePath string, mf *ModuleFile) error {
+	switch mf.Kind {
+	case KindZip:
+		return ValidateZipFile(filePath, module.Version{Path: mf.ModulePath, Version: mf.Version})
+	case KindMod:
+		modulePath, err := ReadModulePath(filePath)
+		if err != nil {
+			return err
+		}
+		if modulePath != mf.ModulePath {
+			return fmt.Errorf("go.mod declares module '%s', expected '%s'", modulePath, mf.ModulePath)
+		}
+	case KindInfo:
+		version, err := ReadInfoVersion(filePath)
+		if err != nil {
+			return err
+		}
+		if version != mf.Version {
+			return fmt.Errorf(".info declares version '%s', expected '%s'", version, mf.Version)
+		}
+	case KindList:
+		return ValidateListFile(filePath)
+	}
+	return nil
+}
+
+// BuildTargetURL constructs the GOPROXY target URL for a module file.
+func (gi *GoModuleImporter) BuildTargetURL(mf *ModuleFile) (string, error) {
+	proxyPath, err := mf.ProxyPath()
+	if err != nil {
+		return "", NewGoModuleError("", mf.ModulePath, mf.Version, fmt.Sprintf("failed to escape GOPROXY path: %v", err))
+	}
+	return fmt.Sprintf("%srepository/%s/%s", utils.NormalizeBaseURL(gi.BaseURL), gi.Repository, path.Join(gi.TargetPrefix, proxyPath)), nil
+}
+
+// LocateGoModuleFile returns the GOPROXY target URL of a module file.
+func (gi *GoModuleImporter) LocateGoModuleFile(filePath string) (string, error) {
+	mf, err := gi.ResolveModuleFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return gi.BuildTargetURL(mf)
+}
+
+// UploadGoModuleFile validates a module file and uploads it to Nexus with retry logic.
+func (gi *GoModuleImporter) UploadGoModuleFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Identify and validate the module file
+	mf, err := gi.ResolveModuleFile(filePath)
+	if err != nil {
+		errorLogger("Rejected Go module file '%s': %v", filePath, err)
+		return fmt.Errorf("invalid Go module file: %w", err)
+	}
+	debugLogger("Parsed module file - Module: %s, Version: %s, Kind: %s", mf.ModulePath, mf.Version, mf.Kind)
+
+	// Step 2: Build the target URL
+	targetURL, err := gi.BuildTargetURL(mf)
+	if err != nil {
+		errorLogger("Failed to build target URL for file '%s': %v", filePath, err)
+		return fmt.Errorf("failed to build target URL: %w", err)
+	}
+	debugLogger("Target URL: %s", targetURL)
+
+	// Step 3: Call `UploadFileWithRetry` with both loggers
+	return importer.UploadFileWithRetry(ctx, gi.HTTPClient, targetURL, filePath, retryAttempts, debugLogger, errorLogger)
+}
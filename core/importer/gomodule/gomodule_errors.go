@@ -0,0 +1,40 @@
+package gomodule
+
+import (
+	"fmt"
+	"iscrie/core/importer"
+	"iscrie/utils"
+)
+
+// GoModuleError represents specific errors for Go module repository.
+type GoModuleError struct {
+	importer.ImportError        // Use generic values
+	ModulePath           string `json:"module_path,omitempty"`
+	Version              string `json:"version,omitempty"`
+}
+
+// NewGoModuleError creates GoModuleError instances.
+func NewGoModuleError(filePath, modulePath, version, errorMessage string) GoModuleError {
+	return GoModuleError{
+		ImportError: importer.ImportError{
+			FilePath:       filePath,
+			RepositoryType: "go",
+			Error:          errorMessage,
+		},
+		ModulePath: modulePath,
+		Version:    version,
+	}
+}
+
+func FormatGoModuleErrorMessage(e GoModuleError) string {
+	return fmt.Sprintf(
+		"Go Module Error - File: %s, Module: %s, Version: %s, Error: %s",
+		e.FilePath, e.ModulePath, e.Version, e.ImportError.Error,
+	)
+}
+
+func (e GoModuleError) Error() string {
+	formattedMessage := FormatGoModuleErrorMessage(e)
+	utils.LogError(formattedMessage)
+	return formattedMessage
+}
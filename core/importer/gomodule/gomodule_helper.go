@@ -0,0 +1,183 @@
+package gomodule
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"iscrie/utils"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	modzip "golang.org/x/mod/zip"
+)
+
+// File kinds served by a GOPROXY under <module>/@v/.
+const (
+	KindInfo = ".info"
+	KindMod  = ".mod"
+	KindZip  = ".zip"
+	KindList = "list"
+)
+
+// ModuleFile identifies a file of the GOPROXY protocol.
+type ModuleFile struct {
+	ModulePath string
+	Version    string // Empty for the version list
+	Kind       string
+}
+
+// ProxyPath returns the escaped GOPROXY path of the file: <module>/@v/<version>.<ext> or <module>/@v/list.
+func (mf ModuleFile) ProxyPath() (string, error) {
+	escapedPath, err := module.EscapePath(mf.ModulePath)
+	if err != nil {
+		return "", err
+	}
+	if mf.Kind == KindList {
+		return escapedPath + "/@v/list", nil
+	}
+	escapedVersion, err := module.EscapeVersion(mf.Version)
+	if err != nil {
+		return "", err
+	}
+	return escapedPath + "/@v/" + escapedVersion + mf.Kind, nil
+}
+
+// IsModuleFile reports whether a file is part of the GOPROXY protocol.
+// Module cache bookkeeping files (.lock, .ziphash, sumdb/...) are not.
+func IsModuleFile(filePath string) bool {
+	switch filepath.Ext(filePath) {
+	case KindInfo, KindMod, KindZip:
+		return true
+	}
+	return filepath.Base(filePath) == KindList && filepath.Base(filepath.Dir(filePath)) == "@v"
+}
+
+// ParseCachePath parses a path laid out as <escaped module>/@v/<escaped version>.<ext>.
+// Escaping is validated: uppercase letters must be encoded as '!' followed by the lowercase letter.
+func ParseCachePath(relativePath string) (*ModuleFile, error) {
+	escapedPath, fileName, found := strings.Cut(relativePath, "/@v/")
+	if !found || strings.Contains(fileName, "/") {
+		return nil, fmt.Errorf("path '%s' does not follow the <module>/@v/<file> layout", relativePath)
+	}
+
+	modulePath, err := module.UnescapePath(escapedPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid module path escaping '%s': %w", escapedPath, err)
+	}
+	if err := module.CheckPath(modulePath); err != nil {
+		return nil, err
+	}
+
+	if fileName == KindList {
+		return &ModuleFile{ModulePath: modulePath, Kind: KindList}, nil
+	}
+
+	kind := path.Ext(fileName)
+	if kind != KindInfo && kind != KindMod && kind != KindZip {
+		return nil, fmt.Errorf("unsupported GOPROXY file '%s'", fileName)
+	}
+	version, err := module.UnescapeVersion(strings.TrimSuffix(fileName, kind))
+	if err != nil {
+		return nil, fmt.Errorf("invalid version escaping '%s': %w", fileName, err)
+	}
+	if err := module.Check(modulePath, version); err != nil {
+		return nil, err
+	}
+
+	return &ModuleFile{ModulePath: modulePath, Version: version, Kind: kind}, nil
+}
+
+// ReadZipModule derives the module path and version from the <module>@<version>/ prefix
+// shared by every entry of a module zip.
+func ReadZipModule(filePath string) (module.Version, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return module.Version{}, fmt.Errorf("failed to open module zip: %w", err)
+	}
+	defer reader.Close()
+
+	if len(reader.File) == 0 {
+		return module.Version{}, fmt.Errorf("module zip is empty")
+	}
+	// Module paths contain slashes but never '@', so the version ends at the first slash after it
+	modulePath, rest, found := strings.Cut(reader.File[0].Name, "@")
+	version, _, hasSlash := strings.Cut(rest, "/")
+	if !found || !hasSlash || modulePath == "" {
+		return module.Version{}, fmt.Errorf("zip entry '%s' is not prefixed with <module>@<version>/", reader.File[0].Name)
+	}
+
+	m := module.Version{Path: modulePath, Version: version}
+	if err := module.Check(m.Path, m.Version); err != nil {
+		return module.Version{}, err
+	}
+	return m, nil
+}
+
+// ValidateZipFile checks the zip structure against the module spec:
+// entry prefixes, file names, case collisions, vendored files and size limits.
+func ValidateZipFile(filePath string, m module.Version) error {
+	checked, err := modzip.CheckZip(m, filePath)
+	if err != nil {
+		return err
+	}
+	if err := checked.Err(); err != nil {
+		return err
+	}
+	utils.LogDebug("Module zip %s@%s contains %d valid files", m.Path, m.Version, len(checked.Valid))
+	return nil
+}
+
+// ReadModulePath returns the module path declared by a go.mod file.
+func ReadModulePath(filePath string) (string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	modulePath := modfile.ModulePath(content)
+	if modulePath == "" {
+		return "", fmt.Errorf("no module directive found in '%s'", filepath.Base(filePath))
+	}
+	return modulePath, nil
+}
+
+// ReadInfoVersion returns the Version field of a .info file.
+func ReadInfoVersion(filePath string) (string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	var info struct {
+		Version string
+	}
+	if err := json.Unmarshal(content, &info); err != nil {
+		return "", fmt.Errorf("invalid .info JSON: %w", err)
+	}
+	if info.Version == "" {
+		return "", fmt.Errorf(".info file has no Version field")
+	}
+	return info.Version, nil
+}
+
+// ValidateListFile checks that every line of a version list is a valid semantic version.
+func ValidateListFile(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !semver.IsValid(line) {
+			return fmt.Errorf("invalid version '%s' in version list", line)
+		}
+	}
+	return scanner.Err()
+}
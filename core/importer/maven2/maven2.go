@@ -2,8 +2,10 @@ package maven2
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"iscrie/config"
 	"iscrie/core/importer"
 	"iscrie/network"
 	"iscrie/utils"
@@ -19,6 +21,7 @@ type Maven2Importer struct {
 	HTTPClient   *network.HTTPClientAdapter
 	RootPath     string
 	ForceReplace bool
+	Signer       *importer.Signer // Optional, uploads a detached signature and its checksums next to every artifact
 }
 
 // NewMaven2Importer creates a new Maven2Importer instance.
@@ -34,6 +37,43 @@ func NewMaven2Importer(baseURL, repository, rootPath string, httpClient *network
 	}
 }
 
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "maven2",
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return newConfiguredMaven2Importer(cfg, httpClient).UploadMaven2File
+		},
+		NewEntryUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.EntryUploadFunc {
+			return newConfiguredMaven2Importer(cfg, httpClient).UploadMaven2Entry
+		},
+		NewLocator: func(cfg *config.Config, httpClient *network.HTTPClient) importer.LocateFunc {
+			return NewMaven2Importer(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace).BuildFullTargetURL
+		},
+	})
+	// Deployment bundles are uploaded to a maven2 repository as well
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "maven2-bundle",
+		Match: func(filePath string) bool {
+			return strings.EqualFold(filepath.Ext(filePath), ".zip")
+		},
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return NewBundleImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace).UploadBundleFile
+		},
+	})
+}
+
+// newConfiguredMaven2Importer creates a Maven2Importer with the signer of the configuration.
+func newConfiguredMaven2Importer(cfg *config.Config, httpClient *network.HTTPClient) *Maven2Importer {
+	maven2Importer := NewMaven2Importer(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace)
+	// The signer was loaded by NewRouter, which stops on its errors
+	signer, err := importer.LoadSigner(cfg.Signing)
+	if err != nil {
+		utils.LogError("Failed to load the signing key, files of repository '%s' are not signed: %v", cfg.Nexus.Repository, err)
+	}
+	maven2Importer.Signer = signer
+	return maven2Importer
+}
+
 // BuildFullTargetURL constructs the full target URL for Maven2 files.
 func (mi *Maven2Importer) BuildFullTargetURL(filePath string) (string, error) {
 	// Log initial path
@@ -83,7 +123,7 @@ func (mi *Maven2Importer) BuildFullTargetURL(filePath string) (string, error) {
 }
 
 // UploadMaven2File uploads a Maven2 artifact to Nexus with detailed logging.
-func (mi *Maven2Importer) UploadMaven2File(filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+func (mi *Maven2Importer) UploadMaven2File(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
 	// Default no-op loggers if nil
 	if debugLogger == nil {
 		debugLogger = func(format string, args ...interface{}) {}
@@ -117,5 +157,43 @@ func (mi *Maven2Importer) UploadMaven2File(filePath string, retryAttempts int, d
 	debugLogger("File preview (first 100 bytes): %q", preview.String())
 
 	// Step 4: Call `UploadFileWithRetry` with both loggers
-	return importer.UploadFileWithRetry(mi.HTTPClient, fullURL, filePath, retryAttempts, debugLogger, errorLogger)
+	if err := importer.UploadFileWithRetry(ctx, mi.HTTPClient, fullURL, filePath, retryAttempts, debugLogger, errorLogger); err != nil {
+		return err
+	}
+
+	// Step 5: Sign the artifact, unless it is a sidecar or already signed
+	if mi.Signer == nil || importer.IsSidecarFile(filePath) || importer.HasSignatureFile(filePath) {
+		return nil
+	}
+	return importer.UploadSignatureWithRetry(ctx, mi.HTTPClient, mi.Signer, fullURL, filePath, func() (io.ReadCloser, error) {
+		return os.Open(filePath)
+	}, true, retryAttempts, debugLogger, errorLogger)
+}
+
+// UploadMaven2Entry uploads a Maven2 artifact from an archive entry, streaming it from the archive.
+func (mi *Maven2Importer) UploadMaven2Entry(ctx context.Context, entry *importer.ArchiveEntry, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Build full URL from the virtual path of the entry
+	fullURL, err := mi.BuildFullTargetURL(entry.Path)
+	if err != nil {
+		errorLogger("Failed to build full URL for entry '%s': %v", entry.Path, err)
+		return fmt.Errorf("failed to build full URL: %w", err)
+	}
+
+	debugLogger("Uploading archive entry: %s", entry.Path)
+	debugLogger("Target URL: %s", fullURL)
+
+	// Step 2: Call `UploadEntryWithRetry` with both loggers, signing the artifact as it streams unless
+	// it is a sidecar or the archive holds its signature
+	if mi.Signer == nil || importer.IsSidecarFile(entry.Path) || entry.HasSignatureEntry() {
+		return importer.UploadEntryWithRetry(ctx, mi.HTTPClient, fullURL, entry, retryAttempts, debugLogger, errorLogger)
+	}
+	return importer.UploadSignedEntryWithRetry(ctx, mi.HTTPClient, mi.Signer, fullURL, entry, true, retryAttempts, debugLogger, errorLogger)
 }
@@ -0,0 +1,368 @@
+package maven2
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"iscrie/core/importer"
+	"iscrie/network"
+	"iscrie/utils"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sidecar files of a bundle: the signature and the checksums, in upload order.
+var (
+	signatureExtension = ".asc"
+	requiredChecksums  = []string{".md5", ".sha1"}
+	optionalChecksums  = []string{".sha256", ".sha512"}
+)
+
+var checksumHashes = map[string]func() hash.Hash{
+	".md5":    md5.New,
+	".sha1":   sha1.New,
+	".sha256": sha256.New,
+	".sha512": sha512.New,
+}
+
+// BundleComponent groups the files of one GAV inside a deployment bundle.
+type BundleComponent struct {
+	GroupID    string
+	ArtifactID string
+	Version    string
+	Directory  string               // <group path>/<artifactId>/<version> inside the bundle
+	Files      map[string]*zip.File // Keyed by file name
+}
+
+// Coordinates formats the component as groupId:artifactId:version.
+func (c *BundleComponent) Coordinates() string {
+	return fmt.Sprintf("%s:%s:%s", c.GroupID, c.ArtifactID, c.Version)
+}
+
+func (c *BundleComponent) pomName() string {
+	return fmt.Sprintf("%s-%s.pom", c.ArtifactID, c.Version)
+}
+
+// BundleImporter handles importing Maven Central-style deployment bundles into a Nexus maven2 hosted repository.
+type BundleImporter struct {
+	BaseURL      string
+	Repository   string
+	HTTPClient   *network.HTTPClientAdapter
+	RootPath     string
+	ForceReplace bool
+}
+
+// NewBundleImporter creates a new BundleImporter instance.
+func NewBundleImporter(baseURL, repository, rootPath string, httpClient *network.HTTPClient, forceReplace bool) *BundleImporter {
+	adapter := network.NewHTTPClientAdapter(httpClient, baseURL, repository, forceReplace)
+
+	return &BundleImporter{
+		BaseURL:      baseURL,
+		Repository:   repository,
+		HTTPClient:   adapter,
+		RootPath:     rootPath,
+		ForceReplace: forceReplace,
+	}
+}
+
+// isSidecar reports whether a file is a signature or checksum of another file.
+func isSidecar(fileName string) bool {
+	extension := path.Ext(fileName)
+	if extension == signatureExtension {
+		return true
+	}
+	_, ok := checksumHashes[extension]
+	return ok
+}
+
+// ReadBundle groups the entries of a bundle by GAV, from their Maven repository layout.
+func ReadBundle(reader *zip.Reader) ([]*BundleComponent, error) {
+	components := map[string]*BundleComponent{}
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		name := path.Clean(file.Name)
+		segments := strings.Split(name, "/")
+		if len(segments) < 4 || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return nil, fmt.Errorf("entry '%s' does not follow the <group>/<artifactId>/<version>/<file> layout", file.Name)
+		}
+
+		directory := path.Dir(name)
+		component, ok := components[directory]
+		if !ok {
+			component = &BundleComponent{
+				GroupID:    strings.Join(segments[:len(segments)-3], "."),
+				ArtifactID: segments[len(segments)-3],
+				Version:    segments[len(segments)-2],
+				Directory:  directory,
+				Files:      map[string]*zip.File{},
+			}
+			components[directory] = component
+		}
+		component.Files[path.Base(name)] = file
+	}
+	if len(components) == 0 {
+		return nil, errors.New("bundle contains no files")
+	}
+
+	sorted := make([]*BundleComponent, 0, len(components))
+	for _, component := range components {
+		sorted = append(sorted, component)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Directory < sorted[j].Directory })
+	return sorted, nil
+}
+
+// readPackaging returns the packaging declared by the POM, "jar" by default, and checks its artifactId.
+func readPackaging(component *BundleComponent, pomFile *zip.File) (string, error) {
+	rc, err := pomFile.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var pom struct {
+		ArtifactID string `xml:"artifactId"`
+		Version    string `xml:"version"`
+		Packaging  string `xml:"packaging"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&pom); err != nil {
+		return "", fmt.Errorf("invalid POM: %w", err)
+	}
+	if pom.ArtifactID != component.ArtifactID {
+		return "", fmt.Errorf("POM artifactId '%s' does not match the bundle path", pom.ArtifactID)
+	}
+	if pom.Version != "" && !strings.Contains(pom.Version, "${") && pom.Version != component.Version {
+		return "", fmt.Errorf("POM version '%s' does not match the bundle path", pom.Version)
+	}
+	if pom.Packaging == "" {
+		return "jar", nil
+	}
+	return pom.Packaging, nil
+}
+
+// verifyChecksum compares a checksum file with the digest of the file it describes.
+func verifyChecksum(file, checksumFile *zip.File, newHash func() hash.Hash) error {
+	rc, err := checksumFile.Open()
+	if err != nil {
+		return err
+	}
+	expected, err := io.ReadAll(io.LimitReader(rc, 1024))
+	rc.Close()
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(expected))
+	if len(fields) == 0 {
+		return fmt.Errorf("%s is empty", checksumFile.Name)
+	}
+
+	rc, err = file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	h := newHash()
+	if _, err := io.Copy(h, rc); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(fields[0], actual) {
+		return fmt.Errorf("%s does not match (expected %s, computed %s)", path.Base(checksumFile.Name), fields[0], actual)
+	}
+	return nil
+}
+
+// ValidateComponent checks that a component has its POM, its main, sources and javadoc artifacts
+// (unless packaged as pom), and a signature and md5/sha1 checksums for every file.
+// Checksums present in the bundle are verified.
+func ValidateComponent(bundlePath string, component *BundleComponent) error {
+	var missing, problems []string
+	require := func(fileName string) bool {
+		if _, ok := component.Files[fileName]; !ok {
+			missing = append(missing, path.Join(component.Directory, fileName))
+			return false
+		}
+		return true
+	}
+
+	prefix := component.ArtifactID + "-" + component.Version
+	if require(component.pomName()) {
+		packaging, err := readPackaging(component, component.Files[component.pomName()])
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else if packaging != "pom" {
+			hasMainArtifact := false
+			for fileName := range component.Files {
+				if !isSidecar(fileName) && fileName != component.pomName() && path.Ext(fileName) != "" &&
+					strings.TrimSuffix(fileName, path.Ext(fileName)) == prefix {
+					hasMainArtifact = true
+				}
+			}
+			if !hasMainArtifact {
+				missing = append(missing, path.Join(component.Directory, fmt.Sprintf("%s.<%s artifact>", prefix, packaging)))
+			}
+			require(prefix + "-sources.jar")
+			require(prefix + "-javadoc.jar")
+		}
+	}
+
+	for _, fileName := range sortedFileNames(component) {
+		if isSidecar(fileName) {
+			continue
+		}
+		if !strings.HasPrefix(fileName, prefix) {
+			problems = append(problems, fmt.Sprintf("'%s' is not named after %s", fileName, prefix))
+			continue
+		}
+		file := component.Files[fileName]
+		require(fileName + signatureExtension)
+		for _, extension := range append(append([]string{}, requiredChecksums...), optionalChecksums...) {
+			checksumFile, ok := component.Files[fileName+extension]
+			if !ok {
+				if !contains(optionalChecksums, extension) {
+					require(fileName + extension)
+				}
+				continue
+			}
+			if err := verifyChecksum(file, checksumFile, checksumHashes[extension]); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+
+	if len(missing) == 0 && len(problems) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("bundle component %s is incomplete", component.Coordinates())
+	if len(problems) > 0 {
+		message = fmt.Sprintf("bundle component %s is invalid: %s", component.Coordinates(), strings.Join(problems, "; "))
+	}
+	return NewMaven2BundleError(bundlePath, component.GroupID, component.ArtifactID, component.Version, missing, message)
+}
+
+func sortedFileNames(component *BundleComponent) []string {
+	names := make([]string, 0, len(component.Files))
+	for fileName := range component.Files {
+		names = append(names, fileName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadOrder lists the files of a component in upload order: every artifact followed by
+// its signature and checksums, sorted by name, and the POM with its own sidecars last.
+func UploadOrder(component *BundleComponent) []string {
+	var order []string
+	appendWithSidecars := func(fileName string) {
+		order = append(order, fileName)
+		for _, extension := range append(append([]string{signatureExtension}, requiredChecksums...), optionalChecksums...) {
+			if _, ok := component.Files[fileName+extension]; ok {
+				order = append(order, fileName+extension)
+			}
+		}
+		// Checksums of the signature, when the bundle provides them
+		for _, extension := range append(append([]string{}, requiredChecksums...), optionalChecksums...) {
+			if _, ok := component.Files[fileName+signatureExtension+extension]; ok {
+				order = append(order, fileName+signatureExtension+extension)
+			}
+		}
+	}
+
+	for _, fileName := range sortedFileNames(component) {
+		if !isSidecar(fileName) && fileName != component.pomName() {
+			appendWithSidecars(fileName)
+		}
+	}
+	appendWithSidecars(component.pomName())
+	return order
+}
+
+// UploadBundleFile validates every component of a bundle, then uploads all of them, artifacts first and POM last.
+// Nothing is uploaded if a component is incomplete. Entries already in Nexus are skipped when force_replace
+// is disabled; network.ErrAssetExists is returned if the whole bundle was.
+func (bi *BundleImporter) UploadBundleFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Read the bundle
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		errorLogger("Failed to open bundle '%s': %v", filePath, err)
+		return NewMaven2Error(filePath, "", "", "", "", fmt.Sprintf("failed to open bundle: %v", err))
+	}
+	defer reader.Close()
+
+	components, err := ReadBundle(&reader.Reader)
+	if err != nil {
+		errorLogger("Rejected bundle '%s': %v", filePath, err)
+		return NewMaven2Error(filePath, "", "", "", "", err.Error())
+	}
+
+	// Step 2: Validate every component before uploading anything
+	var validationErrors []error
+	for _, component := range components {
+		if err := ValidateComponent(filePath, component); err != nil {
+			validationErrors = append(validationErrors, err)
+		}
+	}
+	if len(validationErrors) > 0 {
+		errorLogger("Rejected bundle '%s': %d of %d components are incomplete or invalid", filePath, len(validationErrors), len(components))
+		return errors.Join(validationErrors...)
+	}
+	debugLogger("Validated bundle '%s' with %d components", filePath, len(components))
+
+	// Step 3: Upload each component in order; entries already in Nexus are skipped, so that an interrupted bundle can be resumed
+	uploaded, existing := 0, 0
+	for _, component := range components {
+		debugLogger("Uploading component %s", component.Coordinates())
+		for _, fileName := range UploadOrder(component) {
+			file := component.Files[fileName]
+			entryName := path.Join(component.Directory, fileName)
+			entry := importer.NewArchiveEntry(filepath.Join(filePath, filepath.FromSlash(entryName)), entryName, file.FileInfo(), file.Open)
+
+			targetURL := fmt.Sprintf("%srepository/%s/%s", utils.NormalizeBaseURL(bi.BaseURL), bi.Repository, entryName)
+			debugLogger("Target URL: %s", targetURL)
+			err := importer.UploadEntryWithRetry(ctx, bi.HTTPClient, targetURL, entry, retryAttempts, debugLogger, errorLogger)
+			if errors.Is(err, network.ErrAssetExists) {
+				existing++
+				utils.LogInfo("Skipping bundle entry already in Nexus (force_replace is disabled): %s", entry.Path)
+				continue
+			}
+			if err != nil {
+				return NewMaven2Error(entry.Path, component.GroupID, component.ArtifactID, component.Version, "",
+					fmt.Sprintf("bundle upload stopped: %v", err))
+			}
+			uploaded++
+		}
+	}
+	if uploaded == 0 && existing > 0 {
+		return fmt.Errorf("%w: every entry of bundle %s (force_replace is disabled)", network.ErrAssetExists, filePath)
+	}
+	return nil
+}
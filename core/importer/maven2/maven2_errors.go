@@ -4,15 +4,17 @@ import (
 	"fmt"
 	"iscrie/core/importer"
 	"iscrie/utils"
+	"strings"
 )
 
 // Maven2Error representes specific errors for Maven2 repository.
 type Maven2Error struct {
-	importer.ImportError        // Use generic values
-	GroupID              string `json:"group_id,omitempty"`
-	ArtifactID           string `json:"artifact_id,omitempty"`
-	Version              string `json:"version,omitempty"`
-	Classifier           string `json:"classifier,omitempty"`
+	importer.ImportError          // Use generic values
+	GroupID              string   `json:"group_id,omitempty"`
+	ArtifactID           string   `json:"artifact_id,omitempty"`
+	Version              string   `json:"version,omitempty"`
+	Classifier           string   `json:"classifier,omitempty"`
+	Missing              []string `json:"missing,omitempty"` // Files missing from a deployment bundle
 }
 
 // NewMaven2Error creates Maven2Error instances.
@@ -30,11 +32,22 @@ func NewMaven2Error(filePath, groupID, artifactID, version, classifier, errorMes
 	}
 }
 
+// NewMaven2BundleError creates a Maven2Error listing the files a bundle component is missing.
+func NewMaven2BundleError(filePath, groupID, artifactID, version string, missing []string, errorMessage string) Maven2Error {
+	maven2Error := NewMaven2Error(filePath, groupID, artifactID, version, "", errorMessage)
+	maven2Error.Missing = missing
+	return maven2Error
+}
+
 func FormatMaven2ErrorMessage(e Maven2Error) string {
-	return fmt.Sprintf(
+	message := fmt.Sprintf(
 		"Maven2 Error - File: %s, GroupID: %s, ArtifactID: %s, Version: %s, Classifier: %s, Error: %s",
 		e.FilePath, e.GroupID, e.ArtifactID, e.Version, e.Classifier, e.ImportError.Error,
 	)
+	if len(e.Missing) > 0 {
+		message += fmt.Sprintf(", Missing: %s", strings.Join(e.Missing, ", "))
+	}
+	return message
 }
 
 func (e Maven2Error) Error() string {
@@ -1,11 +1,15 @@
 package raw
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"iscrie/config"
 	"iscrie/core/importer"
 	"iscrie/network"
 	"iscrie/utils"
+	"os"
+	"path"
 	"path/filepath"
 )
 
@@ -16,6 +20,9 @@ type RawImporter struct {
 	HTTPClient   *network.HTTPClientAdapter // ✅ Convertit en HTTPClientAdapter
 	RootPath     string
 	ForceReplace bool
+	TargetPrefix string           // Prepended to the relative path of every file
+	Rewriter     *Rewriter        // Optional rewrite rules applied to the relative path
+	Signer       *importer.Signer // Optional, uploads a detached signature next to every file
 	Config       *config.Config
 }
 
@@ -32,27 +39,76 @@ func NewRawImporter(baseURL, repository, rootPath string, httpClient *network.HT
 	}
 }
 
-// BuildTargetURL constructs the target URL for RAW files.
-func (ri *RawImporter) BuildTargetURL(filePath string) (string, error) {
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "raw",
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return newConfiguredRawImporter(cfg, httpClient).UploadRawFile
+		},
+		NewEntryUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.EntryUploadFunc {
+			return newConfiguredRawImporter(cfg, httpClient).UploadRawEntry
+		},
+		NewLocator: func(cfg *config.Config, httpClient *network.HTTPClient) importer.LocateFunc {
+			return newConfiguredRawImporter(cfg, httpClient).BuildTargetURL
+		},
+		ValidateConfig: validateRawConfig,
+		TargetPrefix:   true,
+	})
+}
+
+// newConfiguredRawImporter creates a RawImporter with the target prefix and rewrite rules of the configuration.
+func newConfiguredRawImporter(cfg *config.Config, httpClient *network.HTTPClient) *RawImporter {
+	rawImporter := NewRawImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace)
+	rawImporter.TargetPrefix = cfg.Nexus.TargetPrefix
+	// Rules were checked by validateRawConfig when the configuration was loaded
+	rawImporter.Rewriter, _ = loadRewriter(cfg)
+	// The signer was loaded by NewRouter, which stops on its errors
+	signer, err := importer.LoadSigner(cfg.Signing)
+	if err != nil {
+		utils.LogError("Failed to load the signing key, files of repository '%s' are not signed: %v", cfg.Nexus.Repository, err)
+	}
+	rawImporter.Signer = signer
+	return rawImporter
+}
+
+// TargetPath returns the path of a file relative to RootPath and its path in the repository,
+// after the rewrite rules and the target prefix.
+func (ri *RawImporter) TargetPath(filePath string) (string, string, error) {
 	if filePath == "" {
-		return "", NewRawError(filePath, "", "file path cannot be empty")
+		return "", "", NewRawError(filePath, "", "file path cannot be empty")
 	}
 
 	normalizedPath, err := filepath.Abs(filePath)
 	if err != nil {
-		return "", NewRawError(filePath, "", fmt.Sprintf("failed to normalize path: %v", err))
+		return "", "", NewRawError(filePath, "", fmt.Sprintf("failed to normalize path: %v", err))
 	}
 
 	relativePath, err := filepath.Rel(ri.RootPath, normalizedPath)
 	if err != nil {
-		return "", NewRawError(normalizedPath, "", fmt.Sprintf("failed to compute relative path: %v", err))
+		return "", "", NewRawError(normalizedPath, "", fmt.Sprintf("failed to compute relative path: %v", err))
 	}
+	relativePath = filepath.ToSlash(relativePath)
 
-	return fmt.Sprintf("%srepository/%s/%s", utils.NormalizeBaseURL(ri.BaseURL), ri.Repository, filepath.ToSlash(relativePath)), nil
+	targetPath := relativePath
+	if ri.Rewriter != nil {
+		if targetPath, err = ri.Rewriter.Rewrite(relativePath); err != nil {
+			return "", "", NewRawError(normalizedPath, relativePath, fmt.Sprintf("failed to rewrite path: %v", err))
+		}
+	}
+	return relativePath, path.Join(ri.TargetPrefix, targetPath), nil
+}
+
+// BuildTargetURL constructs the target URL for RAW files.
+func (ri *RawImporter) BuildTargetURL(filePath string) (string, error) {
+	_, targetPath, err := ri.TargetPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%srepository/%s/%s", utils.NormalizeBaseURL(ri.BaseURL), ri.Repository, targetPath), nil
 }
 
 // UploadRawFile uploads a RAW file to Nexus with retry logic.
-func (ri *RawImporter) UploadRawFile(filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+func (ri *RawImporter) UploadRawFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
 	// Default no-op loggers if nil
 	if debugLogger == nil {
 		debugLogger = func(format string, args ...interface{}) {}
@@ -62,12 +118,61 @@ func (ri *RawImporter) UploadRawFile(filePath string, retryAttempts int, debugLo
 	}
 
 	// Step 1: Build the target URL
-	targetURL, err := ri.BuildTargetURL(filePath)
+	targetURL, err := ri.resolveTargetURL(filePath, debugLogger)
 	if err != nil {
 		errorLogger("Failed to build target URL for file '%s': %v", filePath, err)
 		return fmt.Errorf("failed to build target URL: %w", err)
 	}
 
 	// Step 2: Call `UploadFileWithRetry` with both loggers
-	return importer.UploadFileWithRetry(ri.HTTPClient, targetURL, filePath, retryAttempts, debugLogger, errorLogger)
+	if err := importer.UploadFileWithRetry(ctx, ri.HTTPClient, targetURL, filePath, retryAttempts, debugLogger, errorLogger); err != nil {
+		return err
+	}
+
+	// Step 3: Sign the file, unless it is a sidecar or already signed
+	if ri.Signer == nil || importer.IsSidecarFile(filePath) || importer.HasSignatureFile(filePath) {
+		return nil
+	}
+	return importer.UploadSignatureWithRetry(ctx, ri.HTTPClient, ri.Signer, targetURL, filePath, func() (io.ReadCloser, error) {
+		return os.Open(filePath)
+	}, false, retryAttempts, debugLogger, errorLogger)
+}
+
+// UploadRawEntry uploads an archive entry to Nexus with retry logic, streaming it from the archive.
+func (ri *RawImporter) UploadRawEntry(ctx context.Context, entry *importer.ArchiveEntry, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Build the target URL from the virtual path of the entry
+	targetURL, err := ri.resolveTargetURL(entry.Path, debugLogger)
+	if err != nil {
+		errorLogger("Failed to build target URL for entry '%s': %v", entry.Path, err)
+		return fmt.Errorf("failed to build target URL: %w", err)
+	}
+
+	// Step 2: Call `UploadEntryWithRetry` with both loggers, signing the entry as it streams unless
+	// it is a sidecar or the archive holds its signature
+	if ri.Signer == nil || importer.IsSidecarFile(entry.Path) || entry.HasSignatureEntry() {
+		return importer.UploadEntryWithRetry(ctx, ri.HTTPClient, targetURL, entry, retryAttempts, debugLogger, errorLogger)
+	}
+	return importer.UploadSignedEntryWithRetry(ctx, ri.HTTPClient, ri.Signer, targetURL, entry, false, retryAttempts, debugLogger, errorLogger)
+}
+
+// resolveTargetURL builds the target URL of a file and logs the rewritten path.
+func (ri *RawImporter) resolveTargetURL(filePath string, debugLogger func(format string, args ...interface{})) (string, error) {
+	relativePath, targetPath, err := ri.TargetPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if targetPath != relativePath {
+		debugLogger("Rewrote path '%s' to '%s'", relativePath, targetPath)
+	}
+	targetURL := fmt.Sprintf("%srepository/%s/%s", utils.NormalizeBaseURL(ri.BaseURL), ri.Repository, targetPath)
+	debugLogger("Target URL: %s", targetURL)
+	return targetURL, nil
 }
@@ -0,0 +1,137 @@
+package raw
+
+import (
+	"fmt"
+	"iscrie/config"
+	"iscrie/utils"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rewriteStep transforms a slash-separated target path.
+type rewriteStep func(targetPath string) (string, error)
+
+// Rewriter applies the configured [[raw.rewrite]] rules, in order, to the path of a file relative to RootPath.
+type Rewriter struct {
+	steps []rewriteStep
+}
+
+var (
+	rewritersMutex sync.Mutex
+	rewriters      = map[int64]*Rewriter{}
+)
+
+// loadRewriter returns the Rewriter of the run of cfg. It is built once per run, from its start time,
+// so that the uploaders and locators of every route expand the placeholders to the same date;
+// the rules are those of [raw], shared by every route.
+func loadRewriter(cfg *config.Config) (*Rewriter, error) {
+	rewritersMutex.Lock()
+	defer rewritersMutex.Unlock()
+	key := cfg.General.RunTime.UnixNano()
+	if rewriter, ok := rewriters[key]; ok {
+		return rewriter, nil
+	}
+	rewriter, err := NewRewriter(cfg.Raw.Rewrite, cfg.General.RunTime)
+	if err != nil {
+		return nil, err
+	}
+	rewriters[key] = rewriter
+	return rewriter, nil
+}
+
+// NewRewriter compiles rewrite rules. Prefix placeholders are expanded once, from the given time:
+// ${date} (2006-01-02) and ${datetime} (20060102T150405).
+func NewRewriter(rules []config.RewriteRule, now time.Time) (*Rewriter, error) {
+	placeholders := strings.NewReplacer(
+		"${date}", now.Format("2006-01-02"),
+		"${datetime}", now.Format("20060102T150405"),
+	)
+
+	rewriter := &Rewriter{}
+	for i, rule := range rules {
+		if count := countActions(rule); count != 1 {
+			return nil, fmt.Errorf("raw.rewrite rule %d must set exactly one of strip_components, prefix, regex or lowercase (found %d)", i+1, count)
+		}
+
+		switch {
+		case rule.StripComponents < 0:
+			return nil, fmt.Errorf("raw.rewrite rule %d: strip_components cannot be negative", i+1)
+		case rule.StripComponents > 0:
+			rewriter.steps = append(rewriter.steps, stripComponents(rule.StripComponents))
+		case rule.Prefix != "":
+			prefix := placeholders.Replace(rule.Prefix)
+			if strings.Contains(prefix, "${") {
+				return nil, fmt.Errorf("raw.rewrite rule %d: unknown placeholder in prefix '%s'", i+1, rule.Prefix)
+			}
+			rewriter.steps = append(rewriter.steps, func(targetPath string) (string, error) {
+				return path.Join(prefix, targetPath), nil
+			})
+		case rule.Regex != "":
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("raw.rewrite rule %d: invalid regex: %w", i+1, err)
+			}
+			replace := rule.Replace
+			rewriter.steps = append(rewriter.steps, func(targetPath string) (string, error) {
+				return re.ReplaceAllString(targetPath, replace), nil
+			})
+		case rule.Lowercase:
+			rewriter.steps = append(rewriter.steps, func(targetPath string) (string, error) {
+				return strings.ToLower(targetPath), nil
+			})
+		}
+	}
+	return rewriter, nil
+}
+
+func countActions(rule config.RewriteRule) int {
+	count := 0
+	for _, set := range []bool{rule.StripComponents != 0, rule.Prefix != "", rule.Regex != "", rule.Lowercase} {
+		if set {
+			count++
+		}
+	}
+	if rule.Replace != "" && rule.Regex == "" {
+		count++ // replace without regex is a misconfiguration
+	}
+	return count
+}
+
+// stripComponents removes the n leading directories of a path, like tar --strip-components.
+func stripComponents(n int) rewriteStep {
+	return func(targetPath string) (string, error) {
+		segments := strings.Split(targetPath, "/")
+		if len(segments) <= n {
+			return "", fmt.Errorf("cannot strip %d components from '%s'", n, targetPath)
+		}
+		return strings.Join(segments[n:], "/"), nil
+	}
+}
+
+// Rewrite applies every rule and checks that the result stays inside the repository.
+func (rw *Rewriter) Rewrite(relativePath string) (string, error) {
+	targetPath := relativePath
+	for _, step := range rw.steps {
+		var err error
+		if targetPath, err = step(targetPath); err != nil {
+			return "", err
+		}
+	}
+
+	cleaned := path.Clean(strings.TrimPrefix(targetPath, "/"))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("rewritten path '%s' is outside the repository", targetPath)
+	}
+	return cleaned, nil
+}
+
+// validateRawConfig checks that the rewrite rules compile.
+func validateRawConfig(cfg *config.Config) error {
+	if _, err := NewRewriter(cfg.Raw.Rewrite, cfg.General.RunTime); err != nil {
+		return utils.LogAndReturnError("invalid raw configuration: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"iscrie/config"
+	"iscrie/network"
+)
+
+// UploadFunc uploads a single file to the configured repository with retry logic.
+// The upload is aborted when ctx is done.
+type UploadFunc func(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error
+
+// EntryUploadFunc uploads a single archive entry to the configured repository with retry logic.
+type EntryUploadFunc func(ctx context.Context, entry *ArchiveEntry, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error
+
+// LocateFunc returns the URL at which the uploader stores a file, without uploading it.
+type LocateFunc func(filePath string) (string, error)
+
+// Format describes a repository type handled by an importer.
+// Importer packages register their format from an init function.
+type Format struct {
+	// RepositoryType is the value of nexus.repository_type selecting this format.
+	RepositoryType string
+	// Match reports whether the importer handles a file; other files are skipped.
+	// A nil Match accepts every file.
+	Match func(filePath string) bool
+	// NewUploader builds the upload function for the configured repository.
+	NewUploader func(cfg *config.Config, httpClient *network.HTTPClient) UploadFunc
+	// NewEntryUploader builds the upload function for archive entries (optional).
+	// Formats without it cannot be used when root_path is an archive.
+	NewEntryUploader func(cfg *config.Config, httpClient *network.HTTPClient) EntryUploadFunc
+	// NewLocator builds the function locating uploaded files, used by `iscrie verify` (optional).
+	// Formats whose asset paths are chosen by Nexus cannot be verified.
+	NewLocator func(cfg *config.Config, httpClient *network.HTTPClient) LocateFunc
+	// ValidateConfig checks the format-specific configuration sections (optional).
+	ValidateConfig func(cfg *config.Config) error
+	// TargetPrefix reports whether the uploader honours nexus.target_prefix and route target prefixes.
+	TargetPrefix bool
+}
+
+var formats = map[string]Format{}
+
+// RegisterFormat registers a format and makes its repository type valid in the configuration.
+// It panics if the repository type is registered twice.
+func RegisterFormat(format Format) {
+	if format.RepositoryType == "" || format.NewUploader == nil {
+		panic("importer: RegisterFormat requires a repository type and an uploader")
+	}
+	if _, exists := formats[format.RepositoryType]; exists {
+		panic(fmt.Sprintf("importer: format %q registered twice", format.RepositoryType))
+	}
+	formats[format.RepositoryType] = format
+	config.RegisterRepositoryType(format.RepositoryType, format.ValidateConfig)
+}
+
+// LookupFormat returns the format registered for a repository type.
+func LookupFormat(repositoryType string) (Format, bool) {
+	format, ok := formats[repositoryType]
+	return format, ok
+}
+
+// Matches reports whether the format handles the given file.
+func (f Format) Matches(filePath string) bool {
+	return f.Match == nil || f.Match(filePath)
+}
@@ -0,0 +1,153 @@
+package importer
+
+import (
+	"fmt"
+	"iscrie/config"
+	"iscrie/network"
+	"iscrie/utils"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Route sends the files matching its pattern to a repository through the uploader of its format.
+type Route struct {
+	Name         string
+	Pattern      string // Glob or regex, as configured
+	Repository   string
+	TargetPrefix string
+	Format       Format
+	Upload       UploadFunc
+	UploadEntry  EntryUploadFunc // Nil if the format cannot upload archive entries
+	Locate       LocateFunc      // Nil if the format cannot locate uploaded files
+
+	matcher   *regexp.Regexp // Nil for the default route
+	matchBase bool           // Globs without a slash match the file name only
+}
+
+// Matches reports whether a slash-separated path relative to the root matches the route.
+func (r *Route) Matches(relativePath string) bool {
+	if r.matcher == nil {
+		return true
+	}
+	if r.matchBase {
+		return r.matcher.MatchString(path.Base(relativePath))
+	}
+	return r.matcher.MatchString(relativePath)
+}
+
+// Router picks the route of each file: the first matching [[route]], or the default route built from [nexus].
+type Router struct {
+	RootPath string
+	Routes   []*Route  // Configured routes in order, followed by the default route
+	Verifier *Verifier // Nil unless signatures are verified before upload
+}
+
+// NewRouter builds an uploader for every configured route and for the default route.
+func NewRouter(cfg *config.Config, httpClient *network.HTTPClient) (*Router, error) {
+	router := &Router{RootPath: cfg.General.RootPath}
+
+	// Surface keyring and passphrase errors before the walk; uploaders reuse the loaded signer
+	if _, err := LoadSigner(cfg.Signing); err != nil {
+		return nil, err
+	}
+
+	for _, routeConfig := range cfg.Routes {
+		route, err := newRoute(cfg, httpClient, routeConfig)
+		if err != nil {
+			return nil, err
+		}
+		if routeConfig.Pattern != "" {
+			route.Pattern = routeConfig.Pattern
+			route.matcher, err = utils.CompileGlob(routeConfig.Pattern)
+			route.matchBase = !strings.Contains(routeConfig.Pattern, "/")
+		} else {
+			route.Pattern = routeConfig.Regex
+			route.matcher, err = regexp.Compile(routeConfig.Regex)
+		}
+		if err != nil {
+			return nil, utils.LogAndReturnError("invalid pattern for route '%s': %w", route.Name, err)
+		}
+		router.Routes = append(router.Routes, route)
+	}
+
+	defaultRoute, err := newRoute(cfg, httpClient, config.RouteConfig{
+		Name:           config.DefaultRouteName,
+		Repository:     cfg.Nexus.Repository,
+		RepositoryType: cfg.Nexus.RepositoryType,
+		TargetPrefix:   cfg.Nexus.TargetPrefix,
+		ContentType:    cfg.Nexus.ContentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defaultRoute.Pattern = "**"
+	router.Routes = append(router.Routes, defaultRoute)
+
+	// Every file is verified before its uploader is called
+	if router.Verifier, err = NewVerifier(cfg.Verification); err != nil {
+		return nil, err
+	}
+	if router.Verifier != nil {
+		for _, route := range router.Routes {
+			route.Upload = router.Verifier.Gate(route.Upload)
+		}
+	}
+	return router, nil
+}
+
+// newRoute builds the uploader of a route from a copy of the configuration pointing at its repository.
+func newRoute(cfg *config.Config, httpClient *network.HTTPClient, routeConfig config.RouteConfig) (*Route, error) {
+	format, ok := LookupFormat(routeConfig.RepositoryType)
+	if !ok {
+		return nil, utils.LogAndReturnError("no importer registered for repository type '%s' (route '%s')", routeConfig.RepositoryType, routeConfig.Name)
+	}
+	targetPrefix := strings.Trim(routeConfig.TargetPrefix, "/")
+	if targetPrefix != "" && !format.TargetPrefix {
+		return nil, utils.LogAndReturnError("route '%s': repository type '%s' does not support a target prefix", routeConfig.Name, format.RepositoryType)
+	}
+
+	routeCfg := *cfg
+	routeCfg.Nexus.Repository = routeConfig.Repository
+	routeCfg.Nexus.RepositoryType = routeConfig.RepositoryType
+	routeCfg.Nexus.TargetPrefix = targetPrefix
+
+	// The route client shares the transport and authentication, with the Content-Type of the route
+	routeClient := *httpClient
+	routeClient.ContentType = routeConfig.ContentType
+	httpClient = &routeClient
+
+	route := &Route{
+		Name:         routeConfig.Name,
+		Repository:   routeConfig.Repository,
+		TargetPrefix: targetPrefix,
+		Format:       format,
+		Upload:       format.NewUploader(&routeCfg, httpClient),
+	}
+	if format.NewEntryUploader != nil {
+		route.UploadEntry = format.NewEntryUploader(&routeCfg, httpClient)
+	}
+	if format.NewLocator != nil {
+		route.Locate = format.NewLocator(&routeCfg, httpClient)
+	}
+	return route, nil
+}
+
+// Route returns the first route matching a file under RootPath. The first match wins even if the
+// format of the route does not handle the file: it is not passed on to the next routes.
+func (r *Router) Route(filePath string) (*Route, error) {
+	relativePath, err := filepath.Rel(r.RootPath, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute relative path: %w", err)
+	}
+	relativePath = filepath.ToSlash(relativePath)
+
+	for _, route := range r.Routes {
+		if route.Matches(relativePath) {
+			return route, nil
+		}
+	}
+	// Unreachable: the default route matches every file
+	return r.Routes[len(r.Routes)-1], nil
+}
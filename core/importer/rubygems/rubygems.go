@@ -0,0 +1,94 @@
+package rubygems
+
+import (
+	"context"
+	"fmt"
+	"iscrie/config"
+	"iscrie/core/importer"
+	"iscrie/network"
+	"iscrie/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RubyGemsImporter handles importing gems into a Nexus rubygems hosted repository.
+type RubyGemsImporter struct {
+	BaseURL      string
+	Repository   string
+	HTTPClient   *network.HTTPClientAdapter
+	RootPath     string
+	ForceReplace bool
+}
+
+// NewRubyGemsImporter creates a new RubyGemsImporter instance.
+func NewRubyGemsImporter(baseURL, repository, rootPath string, httpClient *network.HTTPClient, forceReplace bool) *RubyGemsImporter {
+	adapter := network.NewHTTPClientAdapter(httpClient, baseURL, repository, forceReplace)
+
+	return &RubyGemsImporter{
+		BaseURL:      baseURL,
+		Repository:   repository,
+		HTTPClient:   adapter,
+		RootPath:     rootPath,
+		ForceReplace: forceReplace,
+	}
+}
+
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "rubygems",
+		Match: func(filePath string) bool {
+			return strings.EqualFold(filepath.Ext(filePath), ".gem")
+		},
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return NewRubyGemsImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace).UploadGemFile
+		},
+	})
+}
+
+// BuildTargetURL returns the push endpoint used by `gem push`.
+func (ri *RubyGemsImporter) BuildTargetURL() string {
+	return fmt.Sprintf("%srepository/%s/api/v1/gems", utils.NormalizeBaseURL(ri.BaseURL), ri.Repository)
+}
+
+// InspectGemFile reads and validates the specification of a .gem package.
+func (ri *RubyGemsImporter) InspectGemFile(filePath string) (*GemSpec, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, NewRubyGemsError(filePath, nil, fmt.Sprintf("failed to open file: %v", err))
+	}
+	defer file.Close()
+
+	spec, err := ReadGemSpec(file)
+	if err != nil {
+		return nil, NewRubyGemsError(filePath, nil, fmt.Sprintf("failed to read gem specification: %v", err))
+	}
+	if err := ValidateGemSpec(spec); err != nil {
+		return nil, NewRubyGemsError(filePath, spec, err.Error())
+	}
+	return spec, nil
+}
+
+// UploadGemFile validates a gem and pushes it to Nexus with retry logic.
+func (ri *RubyGemsImporter) UploadGemFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Inspect the gem specification
+	spec, err := ri.InspectGemFile(filePath)
+	if err != nil {
+		errorLogger("Rejected gem '%s': %v", filePath, err)
+		return fmt.Errorf("invalid gem: %w", err)
+	}
+	debugLogger("Parsed gem - Name: %s, Version: %s, Platform: %s", spec.Name, spec.Version.Version, spec.Platform)
+
+	// Step 2: POST the gem to the push endpoint
+	targetURL := ri.BuildTargetURL()
+	debugLogger("Target URL: %s", targetURL)
+	return importer.PostFileWithRetry(ctx, ri.HTTPClient, targetURL, filePath, retryAttempts, debugLogger, errorLogger)
+}
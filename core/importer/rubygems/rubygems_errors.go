@@ -0,0 +1,45 @@
+package rubygems
+
+import (
+	"fmt"
+	"iscrie/core/importer"
+	"iscrie/utils"
+)
+
+// RubyGemsError represents specific errors for RubyGems repository.
+type RubyGemsError struct {
+	importer.ImportError        // Use generic values
+	Name                 string `json:"name,omitempty"`
+	Version              string `json:"version,omitempty"`
+	Platform             string `json:"platform,omitempty"`
+}
+
+// NewRubyGemsError creates RubyGemsError instances.
+func NewRubyGemsError(filePath string, spec *GemSpec, errorMessage string) RubyGemsError {
+	gemError := RubyGemsError{
+		ImportError: importer.ImportError{
+			FilePath:       filePath,
+			RepositoryType: "rubygems",
+			Error:          errorMessage,
+		},
+	}
+	if spec != nil {
+		gemError.Name = spec.Name
+		gemError.Version = spec.Version.Version
+		gemError.Platform = spec.Platform
+	}
+	return gemError
+}
+
+func FormatRubyGemsErrorMessage(e RubyGemsError) string {
+	return fmt.Sprintf(
+		"RubyGems Error - File: %s, Name: %s, Version: %s, Platform: %s, Error: %s",
+		e.FilePath, e.Name, e.Version, e.Platform, e.ImportError.Error,
+	)
+}
+
+func (e RubyGemsError) Error() string {
+	formattedMessage := FormatRubyGemsErrorMessage(e)
+	utils.LogError(formattedMessage)
+	return formattedMessage
+}
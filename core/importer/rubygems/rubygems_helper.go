@@ -0,0 +1,79 @@
+package rubygems
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"iscrie/utils"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+var gemNameRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// GemSpec holds the fields of a gem specification used to validate a .gem package.
+type GemSpec struct {
+	Name    string `yaml:"name"`
+	Version struct {
+		Version string `yaml:"version"`
+	} `yaml:"version"`
+	Platform string `yaml:"platform"`
+}
+
+// ReadGemSpec extracts and parses the metadata.gz member of a .gem package.
+// A .gem is a plain tar archive holding metadata.gz, data.tar.gz and checksums.yaml.gz.
+func ReadGemSpec(r io.Reader) (*GemSpec, error) {
+	tr := tar.NewReader(r)
+	hasData := false
+	var spec *GemSpec
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to read gem archive: %w", err)
+		}
+
+		switch hdr.Name {
+		case "data.tar.gz":
+			hasData = true
+		case "metadata.gz":
+			gz, err := gzip.NewReader(tr)
+			if err != nil {
+				return nil, utils.LogAndReturnError("failed to open metadata.gz: %w", err)
+			}
+			content, err := io.ReadAll(gz)
+			gz.Close()
+			if err != nil {
+				return nil, utils.LogAndReturnError("failed to read metadata.gz: %w", err)
+			}
+			spec = &GemSpec{}
+			if err := yaml.Unmarshal(content, spec); err != nil {
+				return nil, utils.LogAndReturnError("failed to parse gem specification: %w", err)
+			}
+		}
+	}
+
+	if spec == nil {
+		return nil, utils.LogAndReturnError("metadata.gz not found in gem archive")
+	}
+	if !hasData {
+		return nil, utils.LogAndReturnError("data.tar.gz not found in gem archive")
+	}
+	return spec, nil
+}
+
+// ValidateGemSpec checks the name and version of a gem specification.
+func ValidateGemSpec(spec *GemSpec) error {
+	if spec.Name == "" || !gemNameRegex.MatchString(spec.Name) {
+		return fmt.Errorf("invalid or missing gem name: %q", spec.Name)
+	}
+	if spec.Version.Version == "" {
+		return fmt.Errorf("missing gem version")
+	}
+	return nil
+}
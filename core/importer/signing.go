@@ -0,0 +1,341 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iscrie/config"
+	"iscrie/network"
+	"iscrie/utils"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// SignatureExtension is the extension of detached ASCII-armored signatures.
+const SignatureExtension = ".asc"
+
+// sidecarExtensions are signatures and checksums, which are never signed themselves.
+var sidecarExtensions = []string{SignatureExtension, ".md5", ".sha1", ".sha256", ".sha512"}
+
+// IsSidecarFile reports whether a file is a signature or checksum of another file.
+func IsSidecarFile(filePath string) bool {
+	extension := strings.ToLower(path.Ext(filePath))
+	for _, sidecar := range sidecarExtensions {
+		if extension == sidecar {
+			return true
+		}
+	}
+	return false
+}
+
+// Signer produces detached ASCII-armored OpenPGP signatures with a decrypted secret key.
+type Signer struct {
+	entity *openpgp.Entity
+	config *packet.Config
+}
+
+var (
+	signersMutex sync.Mutex
+	signers      = map[config.SigningConfig]*Signer{}
+)
+
+// LoadSigner returns the signer of the [signing] section, or nil if signing is disabled.
+// The key is read and decrypted once per configuration.
+func LoadSigner(signingConfig config.SigningConfig) (*Signer, error) {
+	if !signingConfig.Enabled {
+		return nil, nil
+	}
+
+	signersMutex.Lock()
+	defer signersMutex.Unlock()
+	if signer, ok := signers[signingConfig]; ok {
+		return signer, nil
+	}
+
+	signer, err := newSigner(signingConfig)
+	if err != nil {
+		return nil, err
+	}
+	signers[signingConfig] = signer
+	return signer, nil
+}
+
+func newSigner(signingConfig config.SigningConfig) (*Signer, error) {
+	keyringFile, err := os.Open(signingConfig.Keyring)
+	if err != nil {
+		return nil, utils.LogAndReturnError("failed to open signing keyring: %w", err)
+	}
+	defer keyringFile.Close()
+
+	entities, err := openpgp.ReadArmoredKeyRing(keyringFile)
+	if err != nil {
+		return nil, utils.LogAndReturnError("failed to read signing keyring '%s': %w", signingConfig.Keyring, err)
+	}
+
+	entity, keyID, err := selectSigningKey(entities, signingConfig.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := readPassphrase(signingConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := entity.DecryptPrivateKeys(passphrase); err != nil {
+		return nil, utils.LogAndReturnError("failed to decrypt signing key %016X: %w", entity.PrimaryKey.KeyId, err)
+	}
+
+	utils.LogInfo("Signing uploads with key %016X", keyID)
+	return &Signer{
+		entity: entity,
+		config: &packet.Config{SigningKeyId: keyID},
+	}, nil
+}
+
+// selectSigningKey finds the key matching keyID (a key ID or fingerprint, or its suffix),
+// or the first entity with a usable secret signing key.
+func selectSigningKey(entities openpgp.EntityList, keyID string) (*openpgp.Entity, uint64, error) {
+	wanted := normalizeKeyID(keyID)
+	now := time.Now()
+
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		candidates := []*packet.PublicKey{entity.PrimaryKey}
+		for _, subkey := range entity.Subkeys {
+			candidates = append(candidates, subkey.PublicKey)
+		}
+		for _, publicKey := range candidates {
+			if wanted != "" && !keyMatches(publicKey, wanted) {
+				continue
+			}
+			if key, ok := entity.SigningKeyById(now, publicKey.KeyId); ok && key.PrivateKey != nil {
+				return entity, key.PublicKey.KeyId, nil
+			}
+		}
+	}
+
+	if wanted != "" {
+		return nil, 0, utils.LogAndReturnError("no secret signing key matching '%s' in the signing keyring", keyID)
+	}
+	return nil, 0, utils.LogAndReturnError("no secret signing key found in the signing keyring")
+}
+
+// normalizeKeyID uppercases a key ID or fingerprint and drops its spaces and 0x prefix.
+func normalizeKeyID(keyID string) string {
+	return strings.ToUpper(strings.TrimPrefix(strings.ReplaceAll(keyID, " ", ""), "0x"))
+}
+
+// keyMatches reports whether a normalized key ID or fingerprint designates a key:
+// a long or short key ID is a suffix of the fingerprint.
+func keyMatches(publicKey *packet.PublicKey, wanted string) bool {
+	return strings.HasSuffix(strings.ToUpper(hex.EncodeToString(publicKey.Fingerprint)), wanted)
+}
+
+// readPassphrase reads the passphrase from the configured environment variable or file.
+// An empty passphrase is used for unprotected keys.
+func readPassphrase(signingConfig config.SigningConfig) ([]byte, error) {
+	switch {
+	case signingConfig.PassphraseEnv != "":
+		passphrase, ok := os.LookupEnv(signingConfig.PassphraseEnv)
+		if !ok {
+			return nil, utils.LogAndReturnError("environment variable %s holding the signing passphrase is not set", signingConfig.PassphraseEnv)
+		}
+		return []byte(passphrase), nil
+	case signingConfig.PassphraseFile != "":
+		content, err := os.ReadFile(signingConfig.PassphraseFile)
+		if err != nil {
+			return nil, utils.LogAndReturnError("failed to read signing passphrase file: %w", err)
+		}
+		return bytes.TrimRight(content, "\r\n"), nil
+	}
+	return nil, nil
+}
+
+// Sign returns the detached ASCII-armored signature of the content.
+func (s *Signer) Sign(content io.Reader) ([]byte, error) {
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, s.entity, content, s.config); err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	return signature.Bytes(), nil
+}
+
+// UploadSignatureWithRetry signs the content returned by open and uploads the signature to <targetURL>.asc.
+// With checksums, the .md5 and .sha1 of the signature are uploaded as well, as Maven repositories expect.
+func UploadSignatureWithRetry(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	signer *Signer,
+	targetURL, filePath string,
+	open func() (io.ReadCloser, error),
+	checksums bool,
+	retryAttempts int,
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	content, err := open()
+	if err != nil {
+		return fmt.Errorf("failed to open '%s' for signing: %w", filePath, err)
+	}
+	signature, err := signer.Sign(content)
+	content.Close()
+	if err != nil {
+		errorLogger("Failed to sign file '%s': %v", filePath, err)
+		return err
+	}
+	return uploadSignature(ctx, uploader, targetURL, filePath, signature, checksums, retryAttempts, debugLogger, errorLogger)
+}
+
+// UploadSignedEntryWithRetry PUTs an archive entry and its signature. The entry is signed while it
+// streams to Nexus, so that the archive is read once per entry, as reopening a compressed tar
+// entry decompresses the archive again up to it.
+func UploadSignedEntryWithRetry(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	signer *Signer,
+	targetURL string,
+	entry *ArchiveEntry,
+	checksums bool,
+	retryAttempts int,
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	if uploader.HTTPClient.DryRun {
+		if err := UploadEntryWithRetry(ctx, uploader, targetURL, entry, retryAttempts, debugLogger, errorLogger); err != nil {
+			return err
+		}
+		sidecarURLs, _ := signatureSidecars(targetURL, nil, checksums)
+		for _, sidecarURL := range sidecarURLs {
+			utils.LogInfo("[dry-run] %s %s <- %s", http.MethodPut, sidecarURL, entry.Path+SignatureExtension)
+		}
+		return nil
+	}
+
+	// Every attempt signs the body it sends; the signature of the last one is kept
+	var signing *signingReader
+	err := uploadEntryWithRetry(ctx, uploader, targetURL, entry, func(body io.ReadCloser) io.ReadCloser {
+		signing = signer.newSigningReader(body, entry.Info.Size())
+		return signing
+	}, retryAttempts, debugLogger, errorLogger)
+	if err != nil {
+		return err
+	}
+	signature, err := signing.Signature()
+	if err != nil {
+		errorLogger("Failed to sign entry '%s': %v", entry.Path, err)
+		return fmt.Errorf("failed to sign entry '%s': %w", entry.Path, err)
+	}
+	return uploadSignature(ctx, uploader, targetURL, entry.Path, signature, checksums, retryAttempts, debugLogger, errorLogger)
+}
+
+// signatureSidecars returns the URLs of the signature of a file and, with checksums, of its .md5 and .sha1,
+// along with their content.
+func signatureSidecars(targetURL string, signature []byte, checksums bool) ([]string, map[string][]byte) {
+	sidecars := map[string][]byte{targetURL + SignatureExtension: signature}
+	sidecarURLs := []string{targetURL + SignatureExtension}
+	if checksums {
+		md5Sum := md5.Sum(signature)
+		sha1Sum := sha1.Sum(signature)
+		sidecars[targetURL+SignatureExtension+".md5"] = []byte(hex.EncodeToString(md5Sum[:]))
+		sidecars[targetURL+SignatureExtension+".sha1"] = []byte(hex.EncodeToString(sha1Sum[:]))
+		sidecarURLs = append(sidecarURLs, targetURL+SignatureExtension+".md5", targetURL+SignatureExtension+".sha1")
+	}
+	return sidecarURLs, sidecars
+}
+
+// uploadSignature uploads the signature of a file and, with checksums, its .md5 and .sha1.
+func uploadSignature(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	targetURL, filePath string,
+	signature []byte,
+	checksums bool,
+	retryAttempts int,
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	sidecarURLs, sidecars := signatureSidecars(targetURL, signature, checksums)
+	for _, sidecarURL := range sidecarURLs {
+		debugLogger("Uploading signature file: %s", sidecarURL)
+		body := sidecars[sidecarURL]
+		err := SendWithRetry(ctx, uploader, filePath+SignatureExtension, retryAttempts, func() (*http.Request, error) {
+			return uploader.CreateReaderRequest(http.MethodPut, sidecarURL, io.NopCloser(bytes.NewReader(body)), int64(len(body)))
+		}, debugLogger, errorLogger)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errIncompleteBody is the signing error of a body closed before its end, such as a failed attempt.
+var errIncompleteBody = errors.New("the body was not sent whole")
+
+// signingReader passes a request body to the signer as the HTTP client reads it. The signature is
+// complete once the body has been read to its size and closed.
+type signingReader struct {
+	io.ReadCloser
+	size      int64
+	read      atomic.Int64
+	pipe      *io.PipeWriter
+	closeOnce sync.Once
+	done      chan struct{}
+	signature []byte
+	err       error
+}
+
+func (s *Signer) newSigningReader(body io.ReadCloser, size int64) *signingReader {
+	content, pipe := io.Pipe()
+	reader := &signingReader{ReadCloser: body, size: size, pipe: pipe, done: make(chan struct{})}
+	go func() {
+		defer close(reader.done)
+		reader.signature, reader.err = s.Sign(content)
+		content.CloseWithError(reader.err) // Unblocks the reads of the HTTP client if signing failed
+	}()
+	return reader
+}
+
+func (r *signingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.read.Add(int64(n))
+		r.pipe.Write(p[:n]) // Fails only if signing failed, which Signature reports
+	}
+	return n, err
+}
+
+func (r *signingReader) Close() error {
+	r.closeOnce.Do(func() {
+		if r.read.Load() == r.size {
+			r.pipe.Close()
+		} else {
+			r.pipe.CloseWithError(errIncompleteBody)
+		}
+	})
+	return r.ReadCloser.Close()
+}
+
+// Signature waits for the signature of the body, once it has been closed.
+func (r *signingReader) Signature() ([]byte, error) {
+	<-r.done
+	return r.signature, r.err
+}
+
+// HasSignatureFile reports whether a signature already sits next to a file on disk;
+// it is uploaded as a regular file rather than generated.
+func HasSignatureFile(filePath string) bool {
+	_, err := os.Stat(filePath + SignatureExtension)
+	return !errors.Is(err, os.ErrNotExist)
+}
@@ -1,43 +1,330 @@
 package importer
 
 import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"io"
 	"iscrie/network"
 	"iscrie/network/middleware"
+	"iscrie/utils"
 	"net/http"
-	"time"
+	"strings"
 )
 
+// UploadFileWithRetry PUTs a file and, unless disabled, checks that Nexus stored the bytes that were sent.
+// An existing asset is replaced if force_replace is enabled; otherwise network.ErrAssetExists is returned
+// if the redeploy policy of the repository rejects the upload.
 func UploadFileWithRetry(
+	ctx context.Context,
 	uploader *network.HTTPClientAdapter,
 	fullURL, filePath string,
 	retryAttempts int,
 	debugLogger, errorLogger func(format string, args ...interface{}),
 ) error {
-	return middleware.Retry(retryAttempts, 2*time.Second, func() error {
-		// Step 1 : constructs PUT request
-		req, file, err := uploader.CreatePutRequest(fullURL, filePath)
+	if err := uploader.PrepareOverwrite(ctx, fullURL); err != nil {
+		return err
+	}
+	return sendWithRetry(ctx, uploader, filePath, retryAttempts, func() (*http.Request, error) {
+		// The file is closed by the HTTP client once the body has been sent
+		req, _, err := uploader.CreateFileRequest(http.MethodPut, fullURL, filePath)
 		if err != nil {
-			errorLogger("Failed to prepare request for file '%s': %v", filePath, err)
-			return fmt.Errorf("failed to prepare request for file '%s': %w", filePath, err)
+			return nil, err
 		}
-		defer file.Close()
+		return uploader.TrackProgress(ctx, req), nil
+	}, uploader.HTTPClient.VerifyChecksums, debugLogger, errorLogger)
+}
+
+// UploadFileToURLWithRetry PUTs a file to an upload URL handed out by Nexus, such as those of Conan,
+// which is not the path of the asset in the repository: the upload cannot be checked against its checksum.
+func UploadFileToURLWithRetry(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	uploadURL, filePath string,
+	retryAttempts int,
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	if err := uploader.PrepareOverwrite(ctx, uploadURL); err != nil {
+		return err
+	}
+	return sendWithRetry(ctx, uploader, filePath, retryAttempts, func() (*http.Request, error) {
+		// The file is closed by the HTTP client once the body has been sent
+		req, _, err := uploader.CreateFileRequest(http.MethodPut, uploadURL, filePath)
+		if err != nil {
+			return nil, err
+		}
+		return uploader.TrackProgress(ctx, req), nil
+	}, false, debugLogger, errorLogger)
+}
+
+// PostFileWithRetry sends the file as a POST body, for formats whose Nexus endpoint
+// derives the asset path from the package content (apt, ...).
+func PostFileWithRetry(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	fullURL, filePath string,
+	retryAttempts int,
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	return sendFileWithRetry(ctx, uploader, http.MethodPost, fullURL, filePath, retryAttempts, debugLogger, errorLogger)
+}
+
+func sendFileWithRetry(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	method, fullURL, filePath string,
+	retryAttempts int,
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	return SendWithRetry(ctx, uploader, filePath, retryAttempts, func() (*http.Request, error) {
+		// The file is closed by the HTTP client once the body has been sent
+		req, _, err := uploader.CreateFileRequest(method, fullURL, filePath)
+		if err != nil {
+			return nil, err
+		}
+		return uploader.TrackProgress(ctx, req), nil
+	}, debugLogger, errorLogger)
+}
+
+// UploadEntryWithRetry PUTs an archive entry, streaming it from the archive on every attempt.
+func UploadEntryWithRetry(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	fullURL string,
+	entry *ArchiveEntry,
+	retryAttempts int,
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	return uploadEntryWithRetry(ctx, uploader, fullURL, entry, nil, retryAttempts, debugLogger, errorLogger)
+}
 
-		// Step 2 : executes HTTP request via adapter
-		resp, err := uploader.Do(req)
+// uploadEntryWithRetry is UploadEntryWithRetry passing the body of every attempt through wrap, if not nil.
+func uploadEntryWithRetry(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	fullURL string,
+	entry *ArchiveEntry,
+	wrap func(body io.ReadCloser) io.ReadCloser,
+	retryAttempts int,
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	if err := uploader.PrepareOverwrite(ctx, fullURL); err != nil {
+		return err
+	}
+	return sendWithRetry(ctx, uploader, entry.Path, retryAttempts, func() (*http.Request, error) {
+		body, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		if wrap != nil {
+			body = wrap(body)
+		}
+		req, err := uploader.CreateReaderRequest(http.MethodPut, fullURL, body, entry.Info.Size())
 		if err != nil {
-			errorLogger("Failed to upload file '%s': %v", filePath, err)
-			return fmt.Errorf("failed to upload file '%s': %w", filePath, err)
+			return nil, err
+		}
+		return uploader.TrackProgress(ctx, req), nil
+	}, uploader.HTTPClient.VerifyChecksums, debugLogger, errorLogger)
+}
+
+// SendWithRetry executes the request built by newRequest until Nexus accepts it.
+// A fresh request is built for every attempt so that its body can be replayed. Retries stop when ctx is done.
+func SendWithRetry(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	filePath string,
+	retryAttempts int,
+	newRequest func() (*http.Request, error),
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	return sendWithRetry(ctx, uploader, filePath, retryAttempts, newRequest, false, debugLogger, errorLogger)
+}
+
+// sendWithRetry is SendWithRetry with an optional integrity check: the request body is hashed
+// while it is sent and compared with the checksum Nexus reports, a mismatch being retried.
+func sendWithRetry(
+	ctx context.Context,
+	uploader *network.HTTPClientAdapter,
+	filePath string,
+	retryAttempts int,
+	newRequest func() (*http.Request, error),
+	verifyChecksum bool,
+	debugLogger, errorLogger func(format string, args ...interface{}),
+) error {
+	if uploader.HTTPClient.DryRun {
+		return logDryRun(filePath, newRequest)
+	}
+
+	policy := uploader.HTTPClient.Retry
+	policy.Attempts = retryAttempts
+	var existsErr error // Set instead of failing the attempt, as the rejection of a redeploy is not retried
+	record, err := policy.Run(ctx, func(ctx context.Context) error {
+		var req *http.Request
+		var body *hashingReader
+		var sent []io.Closer // Bodies are closed at the end of the attempt; a no-op once the HTTP client has consumed them
+		defer func() {
+			for _, closer := range sent {
+				closer.Close()
+			}
+		}()
+		send := func() (*http.Response, error) {
+			// Step 1 : constructs request
+			var err error
+			if req, err = newRequest(); err != nil {
+				errorLogger("Failed to prepare request for file '%s': %v", filePath, err)
+				return nil, fmt.Errorf("failed to prepare request for file '%s': %w", filePath, err)
+			}
+			body = nil
+			if req.Body != nil {
+				sent = append(sent, req.Body)
+				// The adapter must not replay the body after a 401, the replay would not be hashed
+				req.GetBody = nil
+				if verifyChecksum {
+					body = newHashingReader(req.Body)
+					req.Body = body
+				}
+			}
+
+			// Step 2 : executes HTTP request via adapter
+			resp, err := uploader.Do(ctx, req)
+			if err != nil {
+				errorLogger("Failed to upload file '%s': %v", filePath, err)
+				return nil, fmt.Errorf("failed to upload file '%s': %w", filePath, err)
+			}
+			return resp, nil
+		}
+		resp, err := send()
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && req.Body != nil && uploader.HTTPClient.Authenticator.Renew(req) {
+			// The token was rejected: the request is built again, so that its body is hashed from its start
+			resp.Body.Close()
+			utils.LogInfo("Access token rejected by %s, retrying with a new token", req.URL.Host)
+			resp, err = send()
+		}
+		if err != nil {
+			return err
 		}
 		defer resp.Body.Close()
 
 		// Step 3 : verify HTTP status
 		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+			if !uploader.ForceReplace && uploader.RedeployRejected(resp) {
+				existsErr = fmt.Errorf("%w: %s (force_replace is disabled)", network.ErrAssetExists, req.URL)
+				return nil
+			}
 			errorLogger("Unexpected response status %d for file '%s'", resp.StatusCode, filePath)
-			return fmt.Errorf("unexpected response status %d for file '%s'", resp.StatusCode, filePath)
+			return middleware.NewStatusError(resp, "unexpected response status %d for file '%s'", resp.StatusCode, filePath)
+		}
+
+		// Step 4 : compare what was sent with what Nexus stored
+		if body != nil {
+			if err := checkIntegrity(ctx, uploader, req.URL.String(), filePath, body); err != nil {
+				errorLogger("%v", err)
+				return err
+			}
 		}
 
 		debugLogger("Successfully uploaded file: %s", filePath)
 		return nil
 	})
+	if existsErr != nil {
+		return existsErr
+	}
+	if uploader.HTTPClient.Attempts != nil {
+		uploader.HTTPClient.Attempts.Add(filePath, record)
+	}
+	return err
+}
+
+// logDryRun builds the request once, so that invalid files still fail, and logs it instead of sending it.
+func logDryRun(filePath string, newRequest func() (*http.Request, error)) error {
+	req, err := newRequest()
+	if err != nil {
+		return fmt.Errorf("failed to prepare request for file '%s': %w", filePath, err)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	utils.LogInfo("[dry-run] %s %s <- %s", req.Method, req.URL, filePath)
+	return nil
+}
+
+// IntegrityError reports an upload whose content, as stored by Nexus, differs from the bytes sent.
+type IntegrityError struct {
+	FilePath    string
+	URL         string
+	LocalSHA1   string
+	LocalSHA256 string
+	RemoteSHA1  string
+}
+
+// RetryReason makes the mismatch retryable: the corrupted asset is deleted and uploaded again.
+func (e *IntegrityError) RetryReason() string {
+	return "checksum_mismatch"
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("checksum mismatch for file '%s' at %s: sent sha1 %s (sha256 %s), Nexus reports sha1 %s",
+		e.FilePath, e.URL, e.LocalSHA1, e.LocalSHA256, e.RemoteSHA1)
+}
+
+// hashingReader computes the SHA-1 and SHA-256 of a request body as the HTTP client reads it.
+type hashingReader struct {
+	io.ReadCloser
+	sha1   hash.Hash
+	sha256 hash.Hash
+}
+
+func newHashingReader(body io.ReadCloser) *hashingReader {
+	return &hashingReader{ReadCloser: body, sha1: sha1.New(), sha256: sha256.New()}
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.ReadCloser.Read(p)
+	h.sha1.Write(p[:n])
+	h.sha256.Write(p[:n])
+	return n, err
+}
+
+// checkIntegrity compares the hashes of the sent body with the SHA-1 Nexus computed for the asset.
+// The upload succeeded already: if Nexus reports no checksum, or cannot be asked for it, the asset
+// is accepted unverified with a warning. Only a checksum that differs fails the attempt.
+func checkIntegrity(ctx context.Context, uploader *network.HTTPClientAdapter, assetURL, filePath string, body *hashingReader) error {
+	if !strings.HasPrefix(assetURL, utils.NormalizeBaseURL(uploader.BaseURL)+"repository/"+uploader.Repository+"/") {
+		utils.LogDebug("%s is not an asset path of repository '%s', integrity of '%s' not verified", assetURL, uploader.Repository, filePath)
+		return nil
+	}
+	nexusClient, err := network.NewNexusClient(uploader.BaseURL, uploader)
+	if err != nil {
+		return err
+	}
+	remoteSHA1, err := nexusClient.AssetSHA1(ctx, uploader.Repository, assetURL)
+	if err != nil {
+		utils.LogError("WARNING: integrity of '%s' not verified, its checksum is unavailable: %v", filePath, err)
+		return nil
+	}
+
+	localSHA1 := hex.EncodeToString(body.sha1.Sum(nil))
+	if remoteSHA1 == "" {
+		// Also the case of assets not indexed by the search API yet
+		utils.LogError("WARNING: integrity of '%s' not verified, Nexus reports no checksum for %s", filePath, assetURL)
+		return nil
+	}
+	if remoteSHA1 != localSHA1 {
+		// The corrupted asset is removed so that the retry is not rejected by the redeploy policy
+		if err := uploader.DeleteAsset(ctx, assetURL); err != nil {
+			utils.LogError("Failed to delete corrupted asset %s: %v", assetURL, err)
+		}
+		return &IntegrityError{
+			FilePath:    filePath,
+			URL:         assetURL,
+			LocalSHA1:   localSHA1,
+			LocalSHA256: hex.EncodeToString(body.sha256.Sum(nil)),
+			RemoteSHA1:  remoteSHA1,
+		}
+	}
+	utils.LogDebug("Verified sha1 %s of %s", localSHA1, assetURL)
+	return nil
 }
@@ -0,0 +1,196 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iscrie/config"
+	"iscrie/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+)
+
+// Reasons a file fails signature verification.
+const (
+	SignatureMissing   = "missing signature"
+	SignatureInvalid   = "invalid signature"
+	SignatureUntrusted = "untrusted signature"
+)
+
+// SignatureError reports a file whose signature failed verification. Quarantined files
+// are held back from the upload without counting as failures.
+type SignatureError struct {
+	Path       string
+	Reason     string
+	Quarantine bool
+	Err        error
+}
+
+func (e *SignatureError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s for '%s': %v", e.Reason, e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s for '%s'", e.Reason, e.Path)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// IsQuarantined reports whether an upload error is a file held back by signature verification.
+func IsQuarantined(err error) bool {
+	var signatureErr *SignatureError
+	return errors.As(err, &signatureErr) && signatureErr.Quarantine
+}
+
+// Verifier checks the detached signature found next to each file against a public keyring.
+type Verifier struct {
+	keyring          openpgp.EntityList
+	trustedKeys      []string // Normalized key IDs or fingerprints; empty trusts every key of the keyring
+	requireSignature bool
+	quarantine       bool
+
+	mutex    sync.Mutex
+	verdicts map[string]error // Keyed by artifact path, so that its sidecars share its verdict
+}
+
+// NewVerifier loads the public keyring of the [verification] section, or returns nil if verification is disabled.
+func NewVerifier(verificationConfig config.VerificationConfig) (*Verifier, error) {
+	if !verificationConfig.Enabled {
+		return nil, nil
+	}
+
+	keyringFile, err := os.Open(verificationConfig.Keyring)
+	if err != nil {
+		return nil, utils.LogAndReturnError("failed to open verification keyring: %w", err)
+	}
+	defer keyringFile.Close()
+
+	keyring, err := openpgp.ReadArmoredKeyRing(keyringFile)
+	if err != nil {
+		return nil, utils.LogAndReturnError("failed to read verification keyring '%s': %w", verificationConfig.Keyring, err)
+	}
+
+	verifier := &Verifier{
+		keyring:          keyring,
+		requireSignature: verificationConfig.RequireSignature,
+		quarantine:       verificationConfig.OnFailure == config.OnFailureQuarantine,
+		verdicts:         map[string]error{},
+	}
+	for _, keyID := range verificationConfig.TrustedKeys {
+		wanted := normalizeKeyID(keyID)
+		if findEntity(keyring, wanted) == nil {
+			return nil, utils.LogAndReturnError("trusted key '%s' is not in the verification keyring", keyID)
+		}
+		verifier.trustedKeys = append(verifier.trustedKeys, wanted)
+	}
+	utils.LogInfo("Verifying signatures against %d keys of %s", len(keyring), verificationConfig.Keyring)
+	return verifier, nil
+}
+
+// findEntity returns the entity owning a key, primary or subkey, designated by a normalized key ID or fingerprint.
+func findEntity(keyring openpgp.EntityList, wanted string) *openpgp.Entity {
+	for _, entity := range keyring {
+		if keyMatches(entity.PrimaryKey, wanted) {
+			return entity
+		}
+		for _, subkey := range entity.Subkeys {
+			if keyMatches(subkey.PublicKey, wanted) {
+				return entity
+			}
+		}
+	}
+	return nil
+}
+
+// Verify checks the signature of a file. Signatures and checksums share the verdict of the
+// file they describe, so that they are never uploaded without it.
+func (v *Verifier) Verify(filePath string) error {
+	artifactPath := filePath
+	for IsSidecarFile(artifactPath) {
+		artifactPath = strings.TrimSuffix(artifactPath, filepath.Ext(artifactPath))
+	}
+	if artifactPath != filePath {
+		if _, err := os.Stat(artifactPath); err != nil {
+			return nil // An orphan sidecar is uploaded as any other file
+		}
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	verdict, ok := v.verdicts[artifactPath]
+	if !ok {
+		verdict = v.verify(artifactPath)
+		v.verdicts[artifactPath] = verdict
+	}
+	return verdict
+}
+
+func (v *Verifier) verify(filePath string) error {
+	signature, err := os.Open(filePath + SignatureExtension)
+	if errors.Is(err, os.ErrNotExist) {
+		if !v.requireSignature {
+			return nil
+		}
+		return v.fail(filePath, SignatureMissing, nil)
+	}
+	if err != nil {
+		return v.fail(filePath, SignatureInvalid, err)
+	}
+	defer signature.Close()
+
+	signed, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s' for verification: %w", filePath, err)
+	}
+	defer signed.Close()
+
+	signer, err := openpgp.CheckArmoredDetachedSignature(v.keyring, signed, signature, nil)
+	if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		return v.fail(filePath, SignatureUntrusted, errors.New("signed by a key missing from the verification keyring"))
+	}
+	if err != nil {
+		return v.fail(filePath, SignatureInvalid, err)
+	}
+	if !v.trusted(signer) {
+		return v.fail(filePath, SignatureUntrusted, fmt.Errorf("key %X is not a trusted key", signer.PrimaryKey.Fingerprint))
+	}
+	utils.LogDebug("Verified signature of '%s' by key %X", filePath, signer.PrimaryKey.Fingerprint)
+	return nil
+}
+
+func (v *Verifier) trusted(signer *openpgp.Entity) bool {
+	if len(v.trustedKeys) == 0 {
+		return true
+	}
+	for _, wanted := range v.trustedKeys {
+		if findEntity(openpgp.EntityList{signer}, wanted) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) fail(filePath, reason string, err error) error {
+	return &SignatureError{Path: filePath, Reason: reason, Quarantine: v.quarantine, Err: err}
+}
+
+// Gate wraps an uploader so that files are verified before being uploaded.
+func (v *Verifier) Gate(upload UploadFunc) UploadFunc {
+	return func(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+		if err := v.Verify(filePath); err != nil {
+			if IsQuarantined(err) {
+				utils.LogInfo("Quarantined '%s': %v", filePath, err)
+			} else if errorLogger != nil {
+				errorLogger("Refused '%s': %v", filePath, err)
+			}
+			return err
+		}
+		return upload(ctx, filePath, retryAttempts, debugLogger, errorLogger)
+	}
+}
@@ -0,0 +1,175 @@
+package yum
+
+import (
+	"context"
+	"fmt"
+	"iscrie/config"
+	"iscrie/core/importer"
+	"iscrie/network"
+	"iscrie/utils"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MaxRepodataDepth is the maximum repodata depth accepted by Nexus yum repositories.
+const MaxRepodataDepth = 5
+
+// YumImporter handles importing RPM packages into a Nexus yum hosted repository.
+type YumImporter struct {
+	BaseURL          string
+	Repository       string
+	HTTPClient       *network.HTTPClientAdapter
+	RootPath         string
+	ForceReplace     bool
+	RepodataDepth    int
+	Layout           string
+	RequireSignature bool
+}
+
+// NewYumImporter creates a new YumImporter instance.
+func NewYumImporter(baseURL, repository, rootPath string, httpClient *network.HTTPClient, forceReplace bool, yumConfig config.YumConfig) *YumImporter {
+	adapter := network.NewHTTPClientAdapter(httpClient, baseURL, repository, forceReplace)
+
+	return &YumImporter{
+		BaseURL:          baseURL,
+		Repository:       repository,
+		HTTPClient:       adapter,
+		RootPath:         rootPath,
+		ForceReplace:     forceReplace,
+		RepodataDepth:    yumConfig.RepodataDepth,
+		Layout:           yumConfig.Layout,
+		RequireSignature: yumConfig.RequireSignature,
+	}
+}
+
+func init() {
+	importer.RegisterFormat(importer.Format{
+		RepositoryType: "yum",
+		Match: func(filePath string) bool {
+			return strings.EqualFold(filepath.Ext(filePath), ".rpm")
+		},
+		NewUploader: func(cfg *config.Config, httpClient *network.HTTPClient) importer.UploadFunc {
+			return NewYumImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace, cfg.Yum).UploadYumFile
+		},
+		NewLocator: func(cfg *config.Config, httpClient *network.HTTPClient) importer.LocateFunc {
+			return NewYumImporter(cfg.Nexus.URL, cfg.Nexus.Repository, cfg.General.RootPath, httpClient, cfg.Nexus.ForceReplace, cfg.Yum).LocateYumFile
+		},
+		ValidateConfig: validateYumConfig,
+	})
+}
+
+// validateYumConfig checks that the repodata depth is accepted by Nexus and reachable by the layout.
+func validateYumConfig(cfg *config.Config) error {
+	if cfg.Yum.RepodataDepth < 0 || cfg.Yum.RepodataDepth > MaxRepodataDepth {
+		return utils.LogAndReturnError("yum.repodata_depth must be between 0 and %d", MaxRepodataDepth)
+	}
+	if cfg.Yum.Layout != "" {
+		depth := len(strings.Split(strings.Trim(cfg.Yum.Layout, "/"), "/"))
+		if depth < cfg.Yum.RepodataDepth {
+			return utils.LogAndReturnError("yum.layout '%s' has %d directory levels, fewer than yum.repodata_depth (%d)", cfg.Yum.Layout, depth, cfg.Yum.RepodataDepth)
+		}
+	}
+	return nil
+}
+
+// InspectRPMFile reads and validates the header of an RPM package.
+func (yi *YumImporter) InspectRPMFile(filePath string) (*RPMInfo, error) {
+	if !strings.EqualFold(filepath.Ext(filePath), ".rpm") {
+		return nil, NewYumError(filePath, nil, "not an RPM package (.rpm)")
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, NewYumError(filePath, nil, fmt.Sprintf("failed to open file: %v", err))
+	}
+	defer file.Close()
+
+	info, err := ReadRPMInfo(file)
+	if err != nil {
+		return nil, NewYumError(filePath, nil, fmt.Sprintf("failed to read RPM header: %v", err))
+	}
+
+	if info.Name == "" || info.Version == "" || info.Release == "" || info.Arch == "" {
+		return nil, NewYumError(filePath, info, "RPM header is missing name, version, release or arch")
+	}
+	if yi.RequireSignature && !info.Signed {
+		return nil, NewYumError(filePath, info, "RPM package is not signed and yum.require_signature is enabled")
+	}
+
+	return info, nil
+}
+
+// BuildTargetURL constructs the target URL for an RPM package.
+// The directory comes from the configured layout, or mirrors the path relative to RootPath,
+// and must be at least RepodataDepth levels deep for Nexus to index it.
+func (yi *YumImporter) BuildTargetURL(filePath string, info *RPMInfo) (string, error) {
+	var directory string
+	if yi.Layout != "" {
+		directory = ExpandLayout(yi.Layout, info)
+	} else {
+		normalizedPath, err := filepath.Abs(filePath)
+		if err != nil {
+			return "", NewYumError(filePath, info, fmt.Sprintf("failed to normalize path: %v", err))
+		}
+		relativePath, err := filepath.Rel(yi.RootPath, normalizedPath)
+		if err != nil {
+			return "", NewYumError(filePath, info, fmt.Sprintf("failed to compute relative path: %v", err))
+		}
+		directory = path.Dir(filepath.ToSlash(relativePath))
+	}
+
+	depth := 0
+	if directory != "" && directory != "." {
+		depth = len(strings.Split(directory, "/"))
+	}
+	if depth < yi.RepodataDepth {
+		return "", NewYumError(filePath, info,
+			fmt.Sprintf("target directory '%s' is %d levels deep, repodata_depth requires at least %d", directory, depth, yi.RepodataDepth))
+	}
+
+	targetPath := path.Join(directory, filepath.Base(filePath))
+	return fmt.Sprintf("%srepository/%s/%s", utils.NormalizeBaseURL(yi.BaseURL), yi.Repository, targetPath), nil
+}
+
+// LocateYumFile returns the target URL of an RPM, read from its header.
+func (yi *YumImporter) LocateYumFile(filePath string) (string, error) {
+	info, err := yi.InspectRPMFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return yi.BuildTargetURL(filePath, info)
+}
+
+// UploadYumFile validates an RPM package and uploads it to Nexus with retry logic.
+// Nexus regenerates the repodata of the enclosing directory after each upload.
+func (yi *YumImporter) UploadYumFile(ctx context.Context, filePath string, retryAttempts int, debugLogger, errorLogger func(format string, args ...interface{})) error {
+	// Default no-op loggers if nil
+	if debugLogger == nil {
+		debugLogger = func(format string, args ...interface{}) {}
+	}
+	if errorLogger == nil {
+		errorLogger = func(format string, args ...interface{}) {}
+	}
+
+	// Step 1: Inspect the RPM header
+	info, err := yi.InspectRPMFile(filePath)
+	if err != nil {
+		errorLogger("Rejected RPM package '%s': %v", filePath, err)
+		return fmt.Errorf("invalid RPM package: %w", err)
+	}
+	debugLogger("Parsed package - Name: %s, Version: %s, Release: %s, Arch: %s, Signed: %v",
+		info.Name, info.Version, info.Release, info.Arch, info.Signed)
+
+	// Step 2: Build the target URL
+	targetURL, err := yi.BuildTargetURL(filePath, info)
+	if err != nil {
+		errorLogger("Failed to build target URL for file '%s': %v", filePath, err)
+		return fmt.Errorf("failed to build target URL: %w", err)
+	}
+	debugLogger("Target URL: %s", targetURL)
+
+	// Step 3: Call `UploadFileWithRetry` with both loggers
+	return importer.UploadFileWithRetry(ctx, yi.HTTPClient, targetURL, filePath, retryAttempts, debugLogger, errorLogger)
+}
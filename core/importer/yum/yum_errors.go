@@ -0,0 +1,47 @@
+package yum
+
+import (
+	"fmt"
+	"iscrie/core/importer"
+	"iscrie/utils"
+)
+
+// YumError represents specific errors for yum repository.
+type YumError struct {
+	importer.ImportError        // Use generic values
+	Name                 string `json:"name,omitempty"`
+	Version              string `json:"version,omitempty"`
+	Release              string `json:"release,omitempty"`
+	Arch                 string `json:"arch,omitempty"`
+}
+
+// NewYumError creates YumError instances.
+func NewYumError(filePath string, info *RPMInfo, errorMessage string) YumError {
+	yumError := YumError{
+		ImportError: importer.ImportError{
+			FilePath:       filePath,
+			RepositoryType: "yum",
+			Error:          errorMessage,
+		},
+	}
+	if info != nil {
+		yumError.Name = info.Name
+		yumError.Version = info.Version
+		yumError.Release = info.Release
+		yumError.Arch = info.Arch
+	}
+	return yumError
+}
+
+func FormatYumErrorMessage(e YumError) string {
+	return fmt.Sprintf(
+		"Yum Error - File: %s, Name: %s, Version: %s, Release: %s, Arch: %s, Error: %s",
+		e.FilePath, e.Name, e.Version, e.Release, e.Arch, e.ImportError.Error,
+	)
+}
+
+func (e YumError) Error() string {
+	formattedMessage := FormatYumErrorMessage(e)
+	utils.LogError(formattedMessage)
+	return formattedMessage
+}
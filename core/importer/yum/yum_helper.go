@@ -0,0 +1,191 @@
+package yum
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iscrie/utils"
+	"strconv"
+	"strings"
+)
+
+const (
+	rpmLeadSize      = 96
+	rpmMaxIndexCount = 0xffff
+	rpmMaxStoreSize  = 256 << 20
+)
+
+var (
+	rpmLeadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+	rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+)
+
+// Header tags read from the main RPM header.
+const (
+	tagName      = 1000
+	tagVersion   = 1001
+	tagRelease   = 1002
+	tagEpoch     = 1003
+	tagArch      = 1022
+	tagSourceRPM = 1044
+)
+
+// Signature tags carrying an OpenPGP signature in the signature header.
+var signatureTags = []uint32{
+	267,  // RPMSIGTAG_DSA
+	268,  // RPMSIGTAG_RSA
+	278,  // RPMSIGTAG_OPENPGP
+	1002, // RPMSIGTAG_PGP
+	1005, // RPMSIGTAG_GPG
+}
+
+// Header value types used by the tags above.
+const (
+	typeInt32  = 4
+	typeString = 6
+)
+
+// RPMInfo holds the package metadata extracted from an RPM header.
+type RPMInfo struct {
+	Name    string
+	Version string
+	Release string
+	Epoch   string
+	Arch    string
+	Source  bool
+	Signed  bool
+}
+
+// rpmHeader is a parsed header structure: its index entries and data store.
+type rpmHeader struct {
+	entries map[uint32]rpmIndexEntry
+	store   []byte
+}
+
+type rpmIndexEntry struct {
+	Type   uint32
+	Offset uint32
+	Count  uint32
+}
+
+// ReadRPMInfo parses the lead, signature header and main header of an RPM package.
+func ReadRPMInfo(r io.Reader) (*RPMInfo, error) {
+	lead := make([]byte, rpmLeadSize)
+	if _, err := io.ReadFull(r, lead); err != nil {
+		return nil, utils.LogAndReturnError("failed to read RPM lead: %w", err)
+	}
+	if !bytes.Equal(lead[:4], rpmLeadMagic) {
+		return nil, utils.LogAndReturnError("not an RPM package: invalid lead magic")
+	}
+
+	signature, size, err := readRPMHeader(r)
+	if err != nil {
+		return nil, utils.LogAndReturnError("failed to read signature header: %w", err)
+	}
+	// The signature header is padded to an 8-byte boundary
+	if pad := (8 - size%8) % 8; pad > 0 {
+		if _, err := io.CopyN(io.Discard, r, int64(pad)); err != nil {
+			return nil, utils.LogAndReturnError("failed to skip signature padding: %w", err)
+		}
+	}
+
+	header, _, err := readRPMHeader(r)
+	if err != nil {
+		return nil, utils.LogAndReturnError("failed to read main header: %w", err)
+	}
+
+	info := &RPMInfo{
+		Name:    header.stringValue(tagName),
+		Version: header.stringValue(tagVersion),
+		Release: header.stringValue(tagRelease),
+		Arch:    header.stringValue(tagArch),
+		Epoch:   header.int32Value(tagEpoch),
+		Source:  header.stringValue(tagSourceRPM) == "",
+	}
+	// Source packages are conventionally published under the "src" architecture
+	if info.Source {
+		info.Arch = "src"
+	}
+	for _, tag := range signatureTags {
+		if _, ok := signature.entries[tag]; ok {
+			info.Signed = true
+			break
+		}
+	}
+
+	return info, nil
+}
+
+// readRPMHeader reads one header structure and returns it with its size in bytes.
+func readRPMHeader(r io.Reader) (*rpmHeader, int, error) {
+	intro := make([]byte, 16)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return nil, 0, err
+	}
+	if !bytes.Equal(intro[:4], rpmHeaderMagic) {
+		return nil, 0, fmt.Errorf("invalid header magic")
+	}
+
+	indexCount := binary.BigEndian.Uint32(intro[8:12])
+	storeSize := binary.BigEndian.Uint32(intro[12:16])
+	if indexCount > rpmMaxIndexCount || storeSize > rpmMaxStoreSize {
+		return nil, 0, fmt.Errorf("header too large (%d entries, %d bytes)", indexCount, storeSize)
+	}
+
+	index := make([]byte, 16*int(indexCount))
+	if _, err := io.ReadFull(r, index); err != nil {
+		return nil, 0, err
+	}
+	store := make([]byte, storeSize)
+	if _, err := io.ReadFull(r, store); err != nil {
+		return nil, 0, err
+	}
+
+	header := &rpmHeader{entries: make(map[uint32]rpmIndexEntry, indexCount), store: store}
+	for i := 0; i < int(indexCount); i++ {
+		entry := index[16*i : 16*(i+1)]
+		header.entries[binary.BigEndian.Uint32(entry[0:4])] = rpmIndexEntry{
+			Type:   binary.BigEndian.Uint32(entry[4:8]),
+			Offset: binary.BigEndian.Uint32(entry[8:12]),
+			Count:  binary.BigEndian.Uint32(entry[12:16]),
+		}
+	}
+
+	return header, 16 + len(index) + len(store), nil
+}
+
+// stringValue returns the NUL-terminated string stored for a tag, or "" if absent.
+func (h *rpmHeader) stringValue(tag uint32) string {
+	entry, ok := h.entries[tag]
+	if !ok || entry.Type != typeString || int(entry.Offset) >= len(h.store) {
+		return ""
+	}
+	value := h.store[entry.Offset:]
+	if end := bytes.IndexByte(value, 0); end >= 0 {
+		value = value[:end]
+	}
+	return string(value)
+}
+
+// int32Value returns the first int32 stored for a tag as a string, or "" if absent.
+func (h *rpmHeader) int32Value(tag uint32) string {
+	entry, ok := h.entries[tag]
+	if !ok || entry.Type != typeInt32 || int(entry.Offset)+4 > len(h.store) {
+		return ""
+	}
+	return strconv.FormatUint(uint64(binary.BigEndian.Uint32(h.store[entry.Offset:])), 10)
+}
+
+// ExpandLayout replaces the ${name}, ${version}, ${release}, ${epoch} and ${arch}
+// placeholders of a layout template with the package metadata.
+func ExpandLayout(layout string, info *RPMInfo) string {
+	replacer := strings.NewReplacer(
+		"${name}", info.Name,
+		"${version}", info.Version,
+		"${release}", info.Release,
+		"${epoch}", info.Epoch,
+		"${arch}", info.Arch,
+	)
+	return strings.Trim(replacer.Replace(layout), "/")
+}
@@ -0,0 +1,117 @@
+package network
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// genericContentType is the type of unknown binary content.
+const genericContentType = "application/octet-stream"
+
+// sniffLength is the number of leading bytes inspected by http.DetectContentType.
+const sniffLength = 512
+
+// defaultContentTypes maps the extensions of repository files to their Content-Type.
+// Extensions missing here are looked up in the system MIME table, then sniffed.
+var defaultContentTypes = map[string]string{
+	".jar":     "application/java-archive",
+	".war":     "application/java-archive",
+	".ear":     "application/java-archive",
+	".aar":     "application/java-archive",
+	".pom":     "application/xml",
+	".xml":     "application/xml",
+	".json":    "application/json",
+	".module":  "application/json",
+	".zip":     "application/zip",
+	".nupkg":   "application/zip",
+	".whl":     "application/zip",
+	".tar":     "application/x-tar",
+	".gz":      "application/gzip",
+	".tgz":     "application/gzip",
+	".bz2":     "application/x-bzip2",
+	".xz":      "application/x-xz",
+	".zst":     "application/zstd",
+	".rpm":     "application/x-rpm",
+	".deb":     "application/vnd.debian.binary-package",
+	".gem":     genericContentType,
+	".crate":   "application/gzip",
+	".conda":   "application/zip",
+	".mod":     "text/plain; charset=utf-8",
+	".info":    "application/json",
+	".asc":     "application/pgp-signature",
+	".md5":     "text/plain; charset=utf-8",
+	".sha1":    "text/plain; charset=utf-8",
+	".sha256":  "text/plain; charset=utf-8",
+	".sha512":  "text/plain; charset=utf-8",
+	".txt":     "text/plain; charset=utf-8",
+	".yaml":    "application/yaml",
+	".yml":     "application/yaml",
+	".svg":     "image/svg+xml",
+	".tar.gz":  "application/gzip",
+	".tar.bz2": "application/x-bzip2",
+}
+
+// ContentTypeByName returns the Content-Type of a file name from the configured extensions,
+// the built-in map and the system MIME table, or "" if the extension is unknown.
+// The longest matching extension wins, so that .tar.gz is preferred to .gz.
+func (hc *HTTPClient) ContentTypeByName(name string) string {
+	lowerName := strings.ToLower(path.Base(name))
+	for _, contentTypes := range []map[string]string{hc.ContentTypes, defaultContentTypes} {
+		match := ""
+		for extension := range contentTypes {
+			if strings.HasSuffix(lowerName, extension) && len(extension) > len(match) {
+				match = extension
+			}
+		}
+		if match != "" {
+			return contentTypes[match]
+		}
+	}
+	contentType := mime.TypeByExtension(path.Ext(lowerName))
+	if contentType == genericContentType {
+		return "" // Sniffing can do better
+	}
+	return contentType
+}
+
+// DetectContentType returns the Content-Type of an upload: the forced type of the route,
+// the type of its extension, or the type sniffed from its first bytes.
+func (hc *HTTPClient) DetectContentType(name string, head []byte) string {
+	if hc.ContentType != "" {
+		return hc.ContentType
+	}
+	if contentType := hc.ContentTypeByName(name); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(head)
+}
+
+// fileContentType detects the Content-Type of a file from its name and first bytes.
+// The file offset is left at the start of the file.
+func (hc *HTTPClient) fileContentType(name string, file *os.File) (string, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hc.DetectContentType(name, head[:n]), nil
+}
+
+// readerContentType detects the Content-Type of a streamed body. It returns the body to send,
+// which replays the bytes peeked for sniffing.
+func (hc *HTTPClient) readerContentType(name string, body io.ReadCloser) (string, io.ReadCloser) {
+	buffered := bufio.NewReaderSize(body, sniffLength)
+	head, _ := buffered.Peek(sniffLength)
+	return hc.DetectContentType(name, head), struct {
+		io.Reader
+		io.Closer
+	}{buffered, body}
+}
@@ -1,13 +1,19 @@
 package network
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"iscrie/config"
+	"iscrie/network/middleware"
 	"iscrie/utils"
+	"net"
 	"net/http"
-	"net/url"
+	"net/http/httptrace"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,11 +24,25 @@ const (
 	BasicAuth  AuthType = "basic"
 	BearerAuth AuthType = "bearer"
 	HeaderAuth AuthType = "header"
+	OAuth2Auth AuthType = "oauth2"
 )
 
 type HTTPClient struct {
 	Client        *http.Client
 	Authenticator *Authenticator
+	DryRun        bool // If true, upload requests are logged instead of sent
+	// VerifyChecksums compares the SHA-1 of every PUT body with the checksum Nexus computed for the asset
+	VerifyChecksums bool
+	ContentType     string            // Content-Type forced on every upload, detected per file if empty
+	ContentTypes    map[string]string // Configured Content-Type of file extensions, before the built-in ones
+	Retry           middleware.Policy // Retry policy of uploads; the attempts are set per call
+	Attempts        *middleware.AttemptLog
+	Limiter         *RateLimiter // Bandwidth and request rate limits, none if nil
+	// Concurrency bounds the parallel uploads; every request reports its outcome to it
+	Concurrency *middleware.Concurrency
+	Progress    *Progress // Counts the bytes of the upload bodies passed to TrackProgress
+
+	transport *http.Transport // Its timeouts are set by SetTimeout
 }
 
 // HTTPClientAdapter wraps HTTPClient and implements FileUploader.
@@ -52,10 +72,11 @@ type Authenticator struct {
 	accessToken string
 	headerName  string
 	headerValue string
+	tokens      *tokenSource // OAuth2 tokens, nil for the other types
 }
 
-// NewAuthenticator creates and validates an Authenticator.
-func NewAuthenticator(authConfig config.AuthConfig) (*Authenticator, error) {
+// NewAuthenticator creates and validates an Authenticator. OAuth2 tokens are fetched with tokenClient.
+func NewAuthenticator(authConfig config.AuthConfig, tokenClient *http.Client) (*Authenticator, error) {
 	auth := &Authenticator{
 		authType:    AuthType(authConfig.Type),
 		userToken:   authConfig.UserToken,
@@ -78,6 +99,11 @@ func NewAuthenticator(authConfig config.AuthConfig) (*Authenticator, error) {
 		if auth.headerName == "" || auth.headerValue == "" {
 			return nil, errors.New("header authentication requires headerName and headerValue")
 		}
+	case OAuth2Auth:
+		if authConfig.OAuth2.TokenURL == "" || authConfig.OAuth2.ClientID == "" {
+			return nil, errors.New("oauth2 authentication requires tokenURL and clientID")
+		}
+		auth.tokens = newTokenSource(authConfig.OAuth2, tokenClient)
 	default:
 		return nil, fmt.Errorf("unsupported authentication type: %s", auth.authType)
 	}
@@ -97,63 +123,167 @@ func (a *Authenticator) Apply(req *http.Request) error {
 		utils.LogDebug("Applied BearerAuth with token.")
 	case HeaderAuth:
 		req.Header.Set(a.headerName, a.headerValue)
-		utils.LogDebug("Applied HeaderAuth: %s: %s", a.headerName, a.headerValue)
+		utils.LogDebug("Applied HeaderAuth: %s", a.headerName)
+	case OAuth2Auth:
+		tokenType, accessToken, err := a.tokens.Token(req.Context())
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", tokenType+" "+accessToken)
+		utils.LogDebug("Applied OAuth2 token.")
 	default:
 		return fmt.Errorf("unsupported authentication type: %s", a.authType)
 	}
 	return nil
 }
 
-// NewHTTPClient creates an HTTPClient with optional proxy and authentication.
-func NewHTTPClient(authConfig config.AuthConfig, proxyConfig config.ProxyConfig) (*HTTPClient, error) {
-	authenticator, err := NewAuthenticator(authConfig)
+// Renew drops the OAuth2 token sent with a request that was answered 401, and reports whether the
+// request may be sent again with a new token. It returns false for static credentials.
+func (a *Authenticator) Renew(req *http.Request) bool {
+	if a == nil || a.tokens == nil {
+		return false
+	}
+	_, accessToken, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+	a.tokens.Invalidate(accessToken)
+	return true
+}
+
+// NewHTTPClient creates an HTTPClient with optional proxy, TLS settings and authentication.
+func NewHTTPClient(authConfig config.AuthConfig, proxyConfig config.ProxyConfig, tlsConfig config.TLSConfig) (*HTTPClient, error) {
+	proxy, err := NewProxy(proxyConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize authenticator: %w", err)
+		return nil, fmt.Errorf("failed to initialize proxy: %w", err)
+	}
+	transportTLS, err := NewTLSConfig(tlsConfig, proxy.IsProxyHost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize TLS: %w", err)
 	}
 
-	transport := &http.Transport{
-		Proxy: func(req *http.Request) (*url.URL, error) {
-			if proxyConfig.Enabled {
-				if proxyConfig.Host == "" || proxyConfig.Port == 0 {
-					return nil, errors.New("proxy is enabled but host or port is not defined")
-				}
+	transport := &http.Transport{TLSClientConfig: transportTLS}
+	proxy.Configure(transport)
 
-				proxyURL := &url.URL{
-					Scheme: "http",
-					Host:   fmt.Sprintf("%s:%d", proxyConfig.Host, proxyConfig.Port),
-				}
-				if proxyConfig.Username != "" && proxyConfig.Password != "" {
-					proxyURL.User = url.UserPassword(proxyConfig.Username, proxyConfig.Password)
-				}
-				utils.LogDebug("Proxy configured: %s", proxyURL.String())
-				return proxyURL, nil
-			}
-			return nil, nil
-		},
-		TLSHandshakeTimeout: 10 * time.Second,
-	}
+	// No overall timeout: it would cut the upload of any file slower to send than the limit, see SetTimeout
+	client := &http.Client{Transport: proxy.WrapTransport(transport)}
 
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   30 * time.Second,
+	authenticator, err := NewAuthenticator(authConfig, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize authenticator: %w", err)
 	}
 
-	utils.LogInfo("HTTP client initialized with timeout: 30s")
-	return &HTTPClient{
+	utils.LogInfo("HTTP client initialized")
+	httpClient := &HTTPClient{
 		Client:        client,
 		Authenticator: authenticator,
-	}, nil
+		Retry:         middleware.DefaultPolicy(),
+		Attempts:      middleware.NewAttemptLog(),
+		transport:     transport,
+	}
+	httpClient.SetTimeout(config.DefaultRetryTimeout * time.Second)
+	return httpClient, nil
+}
+
+// SetTimeout bounds the connection, the TLS handshake and the wait for the response headers once the
+// request has been sent. The body is not bounded: its transfer takes as long as its size and the rate limits require.
+func (c *HTTPClient) SetTimeout(timeout time.Duration) {
+	if c.transport == nil {
+		return
+	}
+	c.transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	c.transport.TLSHandshakeTimeout = timeout
+	c.transport.ResponseHeaderTimeout = timeout
+	utils.LogDebug("HTTP timeout to connect and to receive the response headers: %s", timeout)
 }
 
+// ErrAssetExists is returned when the redeploy policy rejects the upload of an existing asset while force_replace is disabled.
+var ErrAssetExists = errors.New("asset already exists")
+
 // AddCommonHeaders adds common headers to an HTTP request.
-func AddCommonHeaders(req *http.Request, forceReplace bool) {
-	req.Header.Set("X-Content-Force-Replace", fmt.Sprintf("%v", forceReplace))
-	req.Header.Set("Content-Type", "application/json")
-	utils.LogDebug("Common headers added: Force-Replace=%v", forceReplace)
+func AddCommonHeaders(req *http.Request, contentType string) {
+	req.Header.Set("Content-Type", contentType)
+	utils.LogDebug("Common headers added: Content-Type=%s", contentType)
+}
+
+// PrepareOverwrite deletes an existing asset before it is uploaded if ForceReplace is set, so that
+// redeploy policies do not reject the upload. Without ForceReplace, the upload is sent as is and the
+// redeploy policy of the repository decides; see RedeployRejected.
+func (hc *HTTPClientAdapter) PrepareOverwrite(ctx context.Context, assetURL string) error {
+	if !hc.ForceReplace {
+		return nil
+	}
+	exists, err := hc.assetExists(ctx, assetURL)
+	if err != nil || !exists {
+		return err
+	}
+	if hc.HTTPClient.DryRun {
+		utils.LogInfo("[dry-run] DELETE %s", assetURL)
+		return nil
+	}
+
+	utils.LogDebug("Deleting existing asset before replacing it: %s", assetURL)
+	return hc.DeleteAsset(ctx, assetURL)
+}
+
+// redeployMessages are the reasons given by Nexus, in the status line or the body, when the redeploy
+// policy rejects the upload of an existing asset.
+var redeployMessages = []string{"does not allow updating assets", "redeploy", "already exists"}
+
+// RedeployRejected reports whether a response to the PUT of an asset is a rejection by the redeploy
+// policy of the repository: a 400 or 409 whose message says so. Other rejections, such as content or
+// layout validation failures, are not, even if the asset exists. It reads the start of the body.
+func (hc *HTTPClientAdapter) RedeployRejected(resp *http.Response) bool {
+	if resp.Request == nil || resp.Request.Method != http.MethodPut ||
+		(resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusConflict) {
+		return false
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	message := strings.ToLower(resp.Status + " " + string(body))
+	for _, reason := range redeployMessages {
+		if strings.Contains(message, reason) {
+			return true
+		}
+	}
+	utils.LogDebug("Response %s to PUT %s is not a redeploy rejection: %s", resp.Status, resp.Request.URL.Redacted(), strings.TrimSpace(string(body)))
+	return false
+}
+
+// assetExists checks whether an asset exists with a HEAD request. Network errors are only logged,
+// the upload reporting them with its retries.
+func (hc *HTTPClientAdapter) assetExists(ctx context.Context, assetURL string) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, assetURL, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := hc.Do(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		utils.LogDebug("Failed to check whether %s exists: %v", assetURL, err)
+		return false, nil
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// DeleteAsset deletes an asset through its repository URL. A missing asset is not an error.
+func (hc *HTTPClientAdapter) DeleteAsset(ctx context.Context, assetURL string) error {
+	req, err := http.NewRequest(http.MethodDelete, assetURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to delete asset %s: %w", assetURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected response status %d when deleting asset %s", resp.StatusCode, assetURL)
+	}
+	return nil
 }
 
 // UploadFile handles uploading a file to the specified URL using a PUT request.
-func (hc *HTTPClient) UploadFile(url string, filePath string, forceReplace bool) (*http.Response, error) {
+func (hc *HTTPClient) UploadFile(ctx context.Context, url string, filePath string, forceReplace bool) (*http.Response, error) {
 	utils.LogInfo("Uploading file: %s to URL: %s", filePath, url)
 
 	file, err := os.Open(filePath)
@@ -162,13 +292,27 @@ func (hc *HTTPClient) UploadFile(url string, filePath string, forceReplace bool)
 	}
 	defer file.Close()
 
-	req, err := http.NewRequest("PUT", url, file)
+	adapter := &HTTPClientAdapter{HTTPClient: hc, ForceReplace: forceReplace}
+	if err := adapter.PrepareOverwrite(ctx, url); err != nil {
+		return nil, err
+	}
+
+	contentType, err := hc.fileContentType(filePath, file)
+	if err != nil {
+		return nil, utils.LogAndReturnError("Failed to read file '%s': %w", filePath, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, file)
 	if err != nil {
 		return nil, utils.LogAndReturnError("Failed to create PUT request: %w", err)
 	}
 
-	AddCommonHeaders(req, forceReplace)
+	req.Body = hc.Limiter.Reader(req.URL.Host, req.Body)
+	AddCommonHeaders(req, contentType)
 
+	if err := hc.Limiter.WaitRequest(ctx, req.URL.Host); err != nil {
+		return nil, utils.LogAndReturnError("HTTP Request failed: %w", err)
+	}
 	resp, err := hc.Client.Do(req)
 	if err != nil {
 		return nil, utils.LogAndReturnError("HTTP Request failed: %w", err)
@@ -180,7 +324,17 @@ func (hc *HTTPClient) UploadFile(url string, filePath string, forceReplace bool)
 
 // CreatePutRequest prepares a PUT request for uploading a file.
 func (hc *HTTPClientAdapter) CreatePutRequest(urlStr, filePath string) (*http.Request, *os.File, error) {
-	utils.LogDebug("Preparing PUT request for URL: %s", urlStr)
+	return hc.CreateFileRequest(http.MethodPut, urlStr, filePath)
+}
+
+// CreatePostRequest prepares a POST request sending the file as the raw request body.
+func (hc *HTTPClientAdapter) CreatePostRequest(urlStr, filePath string) (*http.Request, *os.File, error) {
+	return hc.CreateFileRequest(http.MethodPost, urlStr, filePath)
+}
+
+// CreateFileRequest prepares a request of the given method whose body is the file content.
+func (hc *HTTPClientAdapter) CreateFileRequest(method, urlStr, filePath string) (*http.Request, *os.File, error) {
+	utils.LogDebug("Preparing %s request for URL: %s", method, urlStr)
 	utils.LogDebug("File path: %s", filePath)
 
 	file, err := os.Open(filePath)
@@ -199,20 +353,85 @@ func (hc *HTTPClientAdapter) CreatePutRequest(urlStr, filePath string) (*http.Re
 		return nil, nil, utils.LogAndReturnError("File '%s' is empty", filePath)
 	}
 
-	req, err := http.NewRequest(http.MethodPut, urlStr, file)
+	contentType, err := hc.HTTPClient.fileContentType(filePath, file)
+	if err != nil {
+		file.Close()
+		return nil, nil, utils.LogAndReturnError("Failed to read file '%s': %w", filePath, err)
+	}
+
+	req, err := http.NewRequest(method, urlStr, file)
 	if err != nil {
 		file.Close()
-		return nil, nil, utils.LogAndReturnError("Failed to create PUT request: %w", err)
+		return nil, nil, utils.LogAndReturnError("Failed to create %s request: %w", method, err)
+	}
+	req.Body = hc.HTTPClient.Limiter.Reader(req.URL.Host, req.Body)
+	// Reopening the file lets the request be sent again, with a new token or after a redirect
+	req.GetBody = func() (io.ReadCloser, error) {
+		replay, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return hc.HTTPClient.Limiter.Reader(req.URL.Host, replay), nil
 	}
 
-	AddCommonHeaders(req, false)
+	AddCommonHeaders(req, contentType)
 	return req, file, nil
 }
 
-// Do executes a generic HTTP request and logs details about it.
-func (hc *HTTPClientAdapter) Do(req *http.Request) (*http.Response, error) {
+// CreateReaderRequest prepares a request whose body is streamed from a reader of known size,
+// such as an archive entry. The body is closed by the HTTP client once sent.
+func (hc *HTTPClientAdapter) CreateReaderRequest(method, urlStr string, body io.ReadCloser, size int64) (*http.Request, error) {
+	utils.LogDebug("Preparing %s request for URL: %s", method, urlStr)
+
+	if size == 0 {
+		body.Close()
+		return nil, utils.LogAndReturnError("Request body for '%s' is empty", urlStr)
+	}
+
+	contentType, body := hc.HTTPClient.readerContentType(urlStr, body)
+
+	req, err := http.NewRequest(method, urlStr, body)
+	if err != nil {
+		body.Close()
+		return nil, utils.LogAndReturnError("Failed to create %s request: %w", method, err)
+	}
+	req.ContentLength = size
+	req.Body = hc.HTTPClient.Limiter.Reader(req.URL.Host, req.Body)
+
+	AddCommonHeaders(req, contentType)
+	return req, nil
+}
+
+// TrackProgress counts the bytes of the request body as those of the file of ctx, starting its count over.
+// Only the upload of the file itself is tracked, so that its sidecars and probes leave its progress alone.
+func (hc *HTTPClientAdapter) TrackProgress(ctx context.Context, req *http.Request) *http.Request {
+	req.Body = hc.HTTPClient.Progress.Reader(ctx, req.Body)
+	return req
+}
+
+// Do executes a generic HTTP request under the given context and logs details about it.
+// A request answered 401 is sent once more with a new OAuth2 token, if its body can be sent again.
+func (hc *HTTPClientAdapter) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
 	utils.LogDebug("Executing HTTP request...")
+	req = req.WithContext(ctx)
+
+	resp, err := hc.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && hc.HTTPClient.Authenticator.Renew(req) {
+		if retry, ok := hc.rewind(ctx, req); ok {
+			utils.LogInfo("Access token rejected by %s, retrying with a new token", req.URL.Host)
+			resp.Body.Close()
+			return hc.send(ctx, retry)
+		}
+		utils.LogDebug("Access token rejected by %s; the body of the request cannot be reopened here", req.URL.Host)
+	}
+	return resp, nil
+}
 
+// send authenticates and executes a request, once the rate limits allow it.
+func (hc *HTTPClientAdapter) send(ctx context.Context, req *http.Request) (*http.Response, error) {
 	// Apply authentication
 	if hc.HTTPClient.Authenticator != nil {
 		if err := hc.HTTPClient.Authenticator.Apply(req); err != nil {
@@ -220,9 +439,25 @@ func (hc *HTTPClientAdapter) Do(req *http.Request) (*http.Response, error) {
 		}
 	}
 
-	// Execute the request
+	// Wait for the request rate limits, then execute the request
+	if err := hc.HTTPClient.Limiter.WaitRequest(ctx, req.URL.Host); err != nil {
+		return nil, utils.LogAndReturnError("HTTP Request failed: %w", err)
+	}
+	start := time.Now()
+	var written atomic.Int64 // Set by the transport goroutine writing the request
+	written.Store(start.UnixNano())
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { written.Store(time.Now().UnixNano()) },
+	}))
 	resp, err := hc.HTTPClient.Client.Do(req)
 
+	// Report the latency of the server, from the end of the request body to the response
+	statusCode := 0
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	hc.HTTPClient.Concurrency.Observe(start, req.Method, time.Since(time.Unix(0, written.Load())), statusCode, err)
+
 	// Log the response details
 	if resp != nil {
 		utils.LogDebug("HTTP Response Status: %d", resp.StatusCode)
@@ -233,3 +468,22 @@ func (hc *HTTPClientAdapter) Do(req *http.Request) (*http.Response, error) {
 
 	return resp, err
 }
+
+// rewind copies a request to send it again, with a new body from GetBody if it has one.
+// It returns false if the body cannot be sent again, such as a streamed archive entry.
+func (hc *HTTPClientAdapter) rewind(ctx context.Context, req *http.Request) (*http.Request, bool) {
+	retry := req.Clone(ctx)
+	if req.Body == nil || req.Body == http.NoBody {
+		return retry, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		utils.LogError("Failed to reopen the body of %s %s: %v", req.Method, req.URL.Redacted(), err)
+		return nil, false
+	}
+	retry.Body = body
+	return retry, true
+}
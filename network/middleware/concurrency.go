@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"context"
+	"iscrie/utils"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Reasons of a concurrency change, in addition to the failure reasons of Classify
+const (
+	ReasonHealthy = "healthy" // Requests succeed with a normal latency
+	ReasonLatency = "latency" // The latency exceeds latency_factor times the best latency seen
+)
+
+// latencySmoothing is the weight of the last request in the smoothed latency.
+const latencySmoothing = 0.3
+
+// latencyFloor is the lowest best latency used in comparisons, so that the jitter of requests
+// answered in a few milliseconds is not taken for congestion.
+const latencyFloor = 50 * time.Millisecond
+
+// maxTimeline bounds the changes kept for the summary; the earliest ones are dropped first.
+const maxTimeline = 100
+
+// latency tracks the smoothed latency of a kind of request and its lowest value.
+type latency struct {
+	smoothed, best time.Duration
+}
+
+// ConcurrencyChange is an entry of the concurrency timeline. Consecutive increases are merged.
+type ConcurrencyChange struct {
+	At     time.Duration // Since the creation of the controller
+	From   int
+	To     int
+	Reason string
+}
+
+// Concurrency bounds the number of uploads running in parallel. A fixed controller keeps its limit;
+// an adaptive one follows an AIMD scheme: the limit grows by one per round of healthy requests and is
+// multiplied by the decrease factor on 429, 5xx, timeouts, network errors or a latency rise, at most
+// once per round. It is safe for concurrent use.
+type Concurrency struct {
+	mu       sync.Mutex
+	changed  chan struct{} // Closed and replaced when a worker is released or the limit changes
+	inFlight int
+
+	adaptive       bool
+	min, max       int
+	limit          float64
+	latencyFactor  float64
+	decreaseFactor float64
+	latencies      map[string]*latency // By request method, as HEAD checks are faster than uploads
+	lastDecrease   time.Time           // Requests started before it do not decrease the limit again
+
+	start     time.Time
+	initial   int
+	low, high int // Range of the limit during the run
+	timeline  []ConcurrencyChange
+	dropped   int // Changes dropped from the timeline
+}
+
+// NewFixedConcurrency creates a controller running up to n uploads in parallel.
+func NewFixedConcurrency(n int) *Concurrency {
+	n = max(n, 1)
+	return newConcurrency(n, n, n)
+}
+
+// NewAdaptiveConcurrency creates an AIMD controller starting at initial uploads in parallel, between min and max.
+func NewAdaptiveConcurrency(initial, minimum, maximum int, latencyFactor, decreaseFactor float64) *Concurrency {
+	c := newConcurrency(min(max(initial, minimum), maximum), max(minimum, 1), maximum)
+	c.adaptive = true
+	c.latencies = map[string]*latency{}
+	c.latencyFactor = latencyFactor
+	c.decreaseFactor = decreaseFactor
+	return c
+}
+
+func newConcurrency(initial, minimum, maximum int) *Concurrency {
+	return &Concurrency{
+		changed: make(chan struct{}),
+		min:     minimum,
+		max:     maximum,
+		limit:   float64(initial),
+		start:   time.Now(),
+		initial: initial,
+		low:     initial,
+		high:    initial,
+	}
+}
+
+// Adaptive reports whether the limit follows the server health.
+func (c *Concurrency) Adaptive() bool {
+	return c.adaptive
+}
+
+// Limit returns the current number of uploads allowed in parallel.
+func (c *Concurrency) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.limit)
+}
+
+// Acquire waits for a free worker, or returns the error of ctx once it is done.
+func (c *Concurrency) Acquire(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		if c.inFlight < int(c.limit) {
+			c.inFlight++
+			c.mu.Unlock()
+			return nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release frees the worker taken by Acquire.
+func (c *Concurrency) Release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight--
+	c.notify()
+}
+
+// notify wakes up the goroutines waiting in Acquire. c.mu must be held.
+func (c *Concurrency) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Observe adjusts an adaptive limit from the outcome of a request started at start: its status code,
+// or its error if no response was received, and its latency, measured once the request was written.
+// Latencies are compared with those of the requests of the same method. It does nothing on a fixed
+// or nil controller.
+func (c *Concurrency) Observe(start time.Time, method string, elapsed time.Duration, statusCode int, err error) {
+	if c == nil || !c.adaptive {
+		return
+	}
+
+	reason := ReasonHealthy
+	switch {
+	case err != nil:
+		reason, _ = Classify(err)
+		if reason != ReasonTimeout && reason != ReasonNetwork {
+			return // Cancellations and local errors say nothing about the server
+		}
+	case statusCode == http.StatusTooManyRequests:
+		reason = ReasonRateLimited
+	case statusCode == http.StatusRequestTimeout:
+		reason = ReasonRequestTimeout
+	case statusCode >= 500:
+		reason = ReasonServerError
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats, ok := c.latencies[method]
+	if !ok {
+		stats = &latency{smoothed: elapsed, best: elapsed}
+		c.latencies[method] = stats
+	}
+	if reason == ReasonHealthy {
+		stats.smoothed += time.Duration(latencySmoothing * float64(elapsed-stats.smoothed))
+		stats.best = min(stats.best, stats.smoothed)
+		if float64(stats.smoothed) > c.latencyFactor*float64(max(stats.best, latencyFloor)) {
+			reason = ReasonLatency
+		}
+	}
+
+	if reason == ReasonHealthy {
+		// Additive increase: one more worker once a whole round of requests succeeded
+		c.setLimit(min(c.limit+1/c.limit, float64(c.max)), reason)
+		return
+	}
+	if start.Before(c.lastDecrease) {
+		return // The limit already decreased for this round of requests
+	}
+	c.lastDecrease = time.Now()
+	stats.smoothed = stats.best // Judge the next round on its own latency
+	c.setLimit(max(c.limit*c.decreaseFactor, float64(c.min)), reason)
+}
+
+// setLimit changes the limit and records the change of its integer part. c.mu must be held.
+func (c *Concurrency) setLimit(limit float64, reason string) {
+	from, to := int(c.limit), int(limit)
+	c.limit = limit
+	if from == to {
+		return
+	}
+	c.low, c.high = min(c.low, to), max(c.high, to)
+	c.notify()
+	utils.LogDebug("Concurrency %d -> %d (%s)", from, to, reason)
+
+	if last := len(c.timeline) - 1; last >= 0 && reason == ReasonHealthy && c.timeline[last].Reason == ReasonHealthy {
+		c.timeline[last].To = to
+		return
+	}
+	c.timeline = append(c.timeline, ConcurrencyChange{At: time.Since(c.start), From: from, To: to, Reason: reason})
+	if len(c.timeline) > maxTimeline {
+		c.timeline = c.timeline[1:]
+		c.dropped++
+	}
+}
+
+// ConcurrencySummary describes the limit over a run.
+type ConcurrencySummary struct {
+	Initial, Final, Low, High int
+	Timeline                  []ConcurrencyChange
+	Dropped                   int // Earliest changes missing from Timeline
+}
+
+// Summary returns the range and timeline of the limit.
+func (c *Concurrency) Summary() ConcurrencySummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ConcurrencySummary{
+		Initial:  c.initial,
+		Final:    int(c.limit),
+		Low:      c.low,
+		High:     c.high,
+		Timeline: append([]ConcurrencyChange{}, c.timeline...),
+		Dropped:  c.dropped,
+	}
+}
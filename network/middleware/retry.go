@@ -1,31 +1,251 @@
 package middleware
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"iscrie/utils"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-// Retry retries the provided operation up to a given number of attempts, with a specified delay between attempts.
-// It supports exponential backoff and logs errors if a logger is provided.
-func Retry(attempts int, delay time.Duration, operation func() error) error {
-	if attempts <= 0 {
-		return utils.LogAndReturnError("Attempts must be greater than 0")
+// Reasons of a failed attempt
+const (
+	ReasonNetwork        = "network_error"   // Connection refused, reset, DNS failure, ...
+	ReasonTimeout        = "timeout"         // The connection or the response took too long
+	ReasonServerError    = "server_error"    // 5xx
+	ReasonRateLimited    = "rate_limited"    // 429
+	ReasonRequestTimeout = "request_timeout" // 408
+	ReasonClientError    = "client_error"    // Other 4xx, which will not succeed on retry
+	ReasonCanceled       = "canceled"        // The run was interrupted
+	ReasonError          = "error"           // Any other error, not retried
+)
+
+// Policy decides whether and when a failed operation is retried.
+type Policy struct {
+	Attempts   int           // Maximum number of attempts, at least one
+	Backoff    time.Duration // Delay before the second attempt, doubled after every retry
+	MaxBackoff time.Duration // Upper bound of the delay, including the one asked by Retry-After
+}
+
+// DefaultPolicy returns the policy used when the configuration does not set one.
+func DefaultPolicy() Policy {
+	return Policy{Attempts: 3, Backoff: 2 * time.Second, MaxBackoff: time.Minute}
+}
+
+// StatusError reports an unexpected HTTP response status.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // Delay asked by the Retry-After header, zero if none
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// NewStatusError builds the error of an unexpected response, keeping its Retry-After delay.
+func NewStatusError(resp *http.Response, format string, args ...interface{}) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Message:    fmt.Sprintf(format, args...),
 	}
+}
 
-	var lastErr error
-	for attempt := 1; attempt <= attempts; attempt++ {
-		if lastErr = operation(); lastErr == nil {
-			utils.LogDebug("Operation succeeded on attempt %d.", attempt)
-			return nil
+// Retryable is implemented by the errors of an operation that may succeed on retry, such as an
+// upload whose stored checksum differs from the sent content.
+type Retryable interface {
+	RetryReason() string
+}
+
+// Classify returns the reason of a failed attempt and whether the operation should be retried.
+func Classify(err error) (reason string, retry bool) {
+	var statusErr *StatusError
+	var retryable Retryable
+	var netErr net.Error
+	var urlErr *url.Error
+	var certErr *tls.CertificateVerificationError
+	switch {
+	case errors.As(err, &statusErr):
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return ReasonRateLimited, true
+		case statusErr.StatusCode == http.StatusRequestTimeout:
+			return ReasonRequestTimeout, true
+		case statusErr.StatusCode >= 500:
+			return ReasonServerError, true
+		case statusErr.StatusCode >= 400:
+			return ReasonClientError, false
 		}
+		return ReasonError, false
+	case errors.As(err, &retryable):
+		return retryable.RetryReason(), true
+	case errors.Is(err, context.DeadlineExceeded):
+		return ReasonTimeout, true
+	case errors.Is(err, context.Canceled), errors.As(err, &certErr):
+		return ReasonError, false
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ReasonTimeout, true
+	case errors.As(err, &urlErr), errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return ReasonNetwork, true
+	}
+	return ReasonError, false
+}
+
+// Record lists the failed attempts of an operation.
+type Record struct {
+	Attempts int      // Attempts made, including the successful one
+	Reasons  []string // Reason of each failed attempt
+	Failed   bool     // True if no attempt succeeded
+}
 
-		utils.LogError("Attempt %d/%d failed: %v", attempt, attempts, lastErr)
-		if attempt < attempts {
-			utils.LogDebug("Retrying in %s...", delay)
-			time.Sleep(delay)
-			delay *= 2 // Exponential backoff
+// RetryError is returned by Run when no attempt succeeded.
+type RetryError struct {
+	Record
+	Err error // Error of the last attempt
+}
+
+func (e *RetryError) Error() string {
+	reason := e.Reasons[len(e.Reasons)-1]
+	if e.Attempts == 1 {
+		return fmt.Sprintf("operation failed (%s, not retried): %v", reason, e.Err)
+	}
+	return fmt.Sprintf("operation failed after %d attempts (last reason: %s): %v", e.Attempts, reason, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Run executes the operation until it succeeds, fails with an error that is not retryable, runs
+// out of attempts or ctx is done. Attempts have no deadline of their own, so that large bodies can
+// take as long as they need; the HTTP transport bounds the connection and the wait for a response.
+func (p Policy) Run(ctx context.Context, operation func(ctx context.Context) error) (Record, error) {
+	attempts := max(p.Attempts, 1)
+	record := Record{}
+	for attempt := 1; ; attempt++ {
+		record.Attempts = attempt
+		err := operation(ctx)
+		if err == nil {
+			if attempt > 1 {
+				utils.LogDebug("Operation succeeded on attempt %d.", attempt)
+			}
+			return record, nil
+		}
+
+		reason, retry := Classify(err)
+		if ctx.Err() != nil {
+			reason, retry = ReasonCanceled, false // The run was cancelled
+		}
+		record.Reasons = append(record.Reasons, reason)
+		utils.LogError("Attempt %d/%d failed (%s): %v", attempt, attempts, reason, err)
+		if !retry || attempt >= attempts {
+			record.Failed = true
+			return record, &RetryError{Record: record, Err: err}
 		}
+
+		delay := p.delay(attempt, err)
+		utils.LogDebug("Retrying in %s...", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			record.Failed = true
+			return record, &RetryError{Record: record, Err: ctx.Err()}
+		}
+	}
+}
+
+// delay returns the wait before the next attempt: the Retry-After delay of the response if any,
+// the exponential backoff with jitter otherwise, bounded by MaxBackoff. A zero Backoff retries at once.
+func (p Policy) delay(attempt int, err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		if p.MaxBackoff > 0 && statusErr.RetryAfter > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+		return statusErr.RetryAfter
+	}
+
+	if p.Backoff <= 0 {
+		return 0 // Retries without delay
+	}
+	backoff := p.Backoff << (attempt - 1)
+	if backoff <= 0 || backoff>>(attempt-1) != p.Backoff {
+		// The shift overflowed: the backoff is as long as allowed
+		backoff = time.Duration(math.MaxInt64)
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	// Equal jitter: half of the backoff, plus a random part of the other half
+	half := backoff / 2
+	return half + rand.N(half+1)
+}
+
+// parseRetryAfter reads a Retry-After header holding seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
 	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// AttemptLog records, by file, the operations that needed more than one attempt or failed.
+// It is safe for concurrent use.
+type AttemptLog struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewAttemptLog creates an empty AttemptLog.
+func NewAttemptLog() *AttemptLog {
+	return &AttemptLog{records: map[string]Record{}}
+}
+
+// Add records the attempts of the operation on a file, if it was retried or failed.
+func (l *AttemptLog) Add(filePath string, record Record) {
+	if record.Attempts <= 1 && !record.Failed {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records[filePath] = record
+}
+
+// Files returns the recorded files, sorted.
+func (l *AttemptLog) Files() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	files := make([]string, 0, len(l.records))
+	for filePath := range l.records {
+		files = append(files, filePath)
+	}
+	sort.Strings(files)
+	return files
+}
 
-	return utils.LogAndReturnError("Operation failed after %d attempts: %w", attempts, lastErr)
+// Get returns the record of a file.
+func (l *AttemptLog) Get(filePath string) (Record, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record, ok := l.records[filePath]
+	return record, ok
 }
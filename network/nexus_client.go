@@ -1,12 +1,20 @@
 package network
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"iscrie/network/middleware"
 	"iscrie/utils"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -30,7 +38,7 @@ func NewNexusClient(baseURL string, httpClient *HTTPClientAdapter) (*NexusClient
 }
 
 // RepositoryExists checks if a repository exists in Nexus.
-func (c *NexusClient) RepositoryExists(repository string) (bool, error) {
+func (c *NexusClient) RepositoryExists(ctx context.Context, repository string) (bool, error) {
 	if repository == "" {
 		return false, errors.New("repository name cannot be empty")
 	}
@@ -45,7 +53,7 @@ func (c *NexusClient) RepositoryExists(repository string) (bool, error) {
 	}
 
 	// Execute the request using HTTPClientAdapter
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.HTTPClient.Do(ctx, req)
 	if err != nil {
 		return false, utils.LogAndReturnError("Failed to execute repository existence check: %w", err)
 	}
@@ -65,7 +73,7 @@ func (c *NexusClient) RepositoryExists(repository string) (bool, error) {
 }
 
 // UploadFile uploads a file to the specified repository in Nexus.
-func (c *NexusClient) UploadFile(repository, filePath string, forceReplace bool) error {
+func (c *NexusClient) UploadFile(ctx context.Context, repository, filePath string, forceReplace bool) error {
 	if repository == "" {
 		return errors.New("repository name cannot be empty")
 	}
@@ -82,17 +90,28 @@ func (c *NexusClient) UploadFile(repository, filePath string, forceReplace bool)
 	}
 	defer file.Close()
 
+	// Replace or keep an existing asset
+	adapter := *c.HTTPClient
+	adapter.ForceReplace = forceReplace
+	if err := adapter.PrepareOverwrite(ctx, url); err != nil {
+		return err
+	}
+
 	// Create a PUT request
+	contentType, err := c.HTTPClient.HTTPClient.fileContentType(filePath, file)
+	if err != nil {
+		return utils.LogAndReturnError("Failed to read file '%s': %w", filePath, err)
+	}
 	req, err := http.NewRequest(http.MethodPut, url, file)
 	if err != nil {
 		return utils.LogAndReturnError("Failed to create PUT request for file '%s': %w", filePath, err)
 	}
 
 	// Add common headers
-	AddCommonHeaders(req, forceReplace)
+	AddCommonHeaders(req, contentType)
 
 	// Execute the request
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.HTTPClient.Do(ctx, c.HTTPClient.TrackProgress(ctx, req))
 	if err != nil {
 		return utils.LogAndReturnError("Failed to execute upload request for file '%s': %w", filePath, err)
 	}
@@ -100,29 +119,149 @@ func (c *NexusClient) UploadFile(repository, filePath string, forceReplace bool)
 
 	// Check response status
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return utils.LogAndReturnError("Upload failed with status %d for file '%s'", resp.StatusCode, filePath)
+		utils.LogError("Upload failed with status %d for file '%s'", resp.StatusCode, filePath)
+		return middleware.NewStatusError(resp, "upload failed with status %d for file '%s'", resp.StatusCode, filePath)
 	}
 
 	utils.LogInfo("Successfully uploaded file: %s", filePath)
 	return nil
 }
 
-// UploadFileWithRetry retries uploading a file to the specified repository in Nexus.
-func (c *NexusClient) UploadFileWithRetry(repository, filePath string, forceReplace bool, retryAttempts int, backoff time.Duration) error {
-	var lastErr error
+// UploadFileWithRetry retries uploading a file to the specified repository in Nexus, following the retry
+// policy of the HTTP client with the given attempts and initial backoff.
+func (c *NexusClient) UploadFileWithRetry(ctx context.Context, repository, filePath string, forceReplace bool, retryAttempts int, backoff time.Duration) error {
+	policy := c.HTTPClient.HTTPClient.Retry
+	policy.Attempts = retryAttempts
+	policy.Backoff = backoff
+
+	record, err := policy.Run(ctx, func(ctx context.Context) error {
+		utils.LogDebug("Uploading file '%s' to repository '%s'", filePath, repository)
+		return c.UploadFile(ctx, repository, filePath, forceReplace)
+	})
+	if c.HTTPClient.HTTPClient.Attempts != nil {
+		c.HTTPClient.HTTPClient.Attempts.Add(filePath, record)
+	}
+	if err != nil {
+		return utils.LogAndReturnError("Upload failed for file '%s': %w", filePath, err)
+	}
+	utils.LogInfo("File '%s' successfully uploaded after %d attempt(s)", filePath, record.Attempts)
+	return nil
+}
+
+// Asset is a file stored in a Nexus repository, as listed by the assets API.
+type Asset struct {
+	Path        string            `json:"path"`
+	DownloadURL string            `json:"downloadUrl"`
+	Checksum    map[string]string `json:"checksum"` // sha1, sha256, md5, ... as computed by Nexus
+}
+
+// ListAssets returns every asset of a repository, following the continuation tokens of the assets API.
+func (c *NexusClient) ListAssets(ctx context.Context, repository string) ([]Asset, error) {
+	if repository == "" {
+		return nil, errors.New("repository name cannot be empty")
+	}
+
+	var assets []Asset
+	continuationToken := ""
+	for {
+		query := url.Values{"repository": {repository}}
+		if continuationToken != "" {
+			query.Set("continuationToken", continuationToken)
+		}
+		assetsURL := fmt.Sprintf("%sservice/rest/v1/assets?%s", c.BaseURL, query.Encode())
+		utils.LogDebug("Listing assets: %s", assetsURL)
+
+		req, err := http.NewRequest(http.MethodGet, assetsURL, nil)
+		if err != nil {
+			return nil, utils.LogAndReturnError("Failed to create assets request: %w", err)
+		}
+		resp, err := c.HTTPClient.Do(ctx, req)
+		if err != nil {
+			return nil, utils.LogAndReturnError("Failed to list assets of repository '%s': %w", repository, err)
+		}
+
+		var page struct {
+			Items             []Asset `json:"items"`
+			ContinuationToken string  `json:"continuationToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, utils.LogAndReturnError("Unexpected response status %d when listing assets of repository '%s'", resp.StatusCode, repository)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, utils.LogAndReturnError("Failed to decode assets of repository '%s': %w", repository, err)
+		}
 
-	for attempt := 1; attempt <= retryAttempts; attempt++ {
-		utils.LogDebug("Attempt %d/%d: Uploading file '%s' to repository '%s'", attempt, retryAttempts, filePath, repository)
-		lastErr = c.UploadFile(repository, filePath, forceReplace)
-		if lastErr == nil {
-			utils.LogInfo("File '%s' successfully uploaded after %d attempt(s)", filePath, attempt)
-			return nil // Success
+		for _, asset := range page.Items {
+			asset.Path = strings.TrimPrefix(asset.Path, "/")
+			assets = append(assets, asset)
+		}
+		if page.ContinuationToken == "" {
+			return assets, nil
 		}
+		continuationToken = page.ContinuationToken
+	}
+}
+
+// RepositoryPath returns the path of a target URL inside its repository.
+func RepositoryPath(targetURL, repository string) string {
+	marker := "/repository/" + repository + "/"
+	if index := strings.Index(targetURL, marker); index >= 0 {
+		return strings.TrimPrefix(path.Clean("/"+targetURL[index+len(marker):]), "/")
+	}
+	return targetURL
+}
 
-		utils.LogError("Attempt %d/%d failed: %v", attempt, retryAttempts, lastErr)
-		time.Sleep(backoff)
-		backoff *= 2 // Exponential backoff
+// AssetSHA1 returns the SHA-1 Nexus computed for an uploaded asset, from the <asset>.sha1 endpoint
+// or, for formats without it, from the search API. It returns "" if Nexus reports none.
+func (c *NexusClient) AssetSHA1(ctx context.Context, repository, assetURL string) (string, error) {
+	// Step 1: <asset>.sha1, served for maven2 and for any asset uploaded with its checksum
+	req, err := http.NewRequest(http.MethodGet, assetURL+".sha1", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HTTPClient.Do(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksum of %s: %w", assetURL, err)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	if err == nil && resp.StatusCode == http.StatusOK {
+		if fields := strings.Fields(string(body)); len(fields) > 0 && sha1Pattern.MatchString(fields[0]) {
+			return strings.ToLower(fields[0]), nil
+		}
 	}
 
-	return utils.LogAndReturnError("Upload failed after %d attempts for file '%s'", retryAttempts, filePath)
+	// Step 2: search the asset by repository and path
+	assetPath := RepositoryPath(assetURL, repository)
+	query := url.Values{"repository": {repository}, "name": {assetPath}}
+	searchURL := fmt.Sprintf("%sservice/rest/v1/search/assets?%s", c.BaseURL, query.Encode())
+	req, err = http.NewRequest(http.MethodGet, searchURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err = c.HTTPClient.Do(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to search asset %s: %w", assetPath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response status %d when searching asset %s", resp.StatusCode, assetPath)
+	}
+	var page struct {
+		Items []Asset `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return "", fmt.Errorf("failed to decode search result for asset %s: %w", assetPath, err)
+	}
+	for _, asset := range page.Items {
+		if strings.TrimPrefix(asset.Path, "/") == assetPath {
+			return strings.ToLower(asset.Checksum["sha1"]), nil
+		}
+	}
+	return "", nil
 }
+
+var sha1Pattern = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
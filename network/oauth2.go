@@ -0,0 +1,135 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iscrie/config"
+	"iscrie/utils"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenSource fetches the access tokens of the oauth2 authentication and caches them until
+// refresh_before seconds before they expire. It is safe for concurrent use.
+type tokenSource struct {
+	config config.OAuth2Config
+	client *http.Client // Same proxy and TLS settings as the uploads
+
+	mu           sync.Mutex
+	accessToken  string
+	tokenType    string
+	expiry       time.Time // Zero if the endpoint gave no lifetime
+	refreshToken string    // Refresh token grant if set, replaced when the endpoint rotates it
+}
+
+// tokenResponse is the JSON body of a token endpoint response (RFC 6749, section 5).
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func newTokenSource(oauth2Config config.OAuth2Config, client *http.Client) *tokenSource {
+	return &tokenSource{config: oauth2Config, client: client, refreshToken: oauth2Config.RefreshToken}
+}
+
+// Token returns the cached access token, fetching a new one if there is none or it expires soon.
+func (s *tokenSource) Token(ctx context.Context) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refreshBefore := time.Duration(s.config.RefreshBefore) * time.Second
+	if s.accessToken != "" && (s.expiry.IsZero() || time.Now().Add(refreshBefore).Before(s.expiry)) {
+		return s.tokenType, s.accessToken, nil
+	}
+	if err := s.fetch(ctx); err != nil {
+		return "", "", err
+	}
+	return s.tokenType, s.accessToken, nil
+}
+
+// Invalidate drops the cached token if it is still the one given, so that the next call to Token fetches a new one.
+func (s *tokenSource) Invalidate(accessToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accessToken == accessToken {
+		s.accessToken = ""
+	}
+}
+
+// fetch requests a token from the endpoint. s.mu must be held.
+func (s *tokenSource) fetch(ctx context.Context) error {
+	form := url.Values{}
+	if s.refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", s.refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+	if s.config.AuthStyle == "body" {
+		form.Set("client_id", s.config.ClientID)
+		if s.config.ClientSecret != "" {
+			form.Set("client_secret", s.config.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.config.AuthStyle != "body" {
+		req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+	}
+
+	utils.LogDebug("Requesting OAuth2 token (%s) from %s", form.Get("grant_type"), s.config.TokenURL)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("token request to %s failed: %w", s.config.TokenURL, err)
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read token response: %w", err)
+	}
+	if err := json.Unmarshal(body, &token); err != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("invalid token response from %s: %w", s.config.TokenURL, err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		if token.Error != "" {
+			return fmt.Errorf("token endpoint %s refused the request with status %d: %s %s",
+				s.config.TokenURL, resp.StatusCode, token.Error, token.ErrorDescription)
+		}
+		return fmt.Errorf("token endpoint %s returned status %d without an access token", s.config.TokenURL, resp.StatusCode)
+	}
+
+	utils.RegisterSecret(token.AccessToken, token.RefreshToken)
+	s.accessToken = token.AccessToken
+	s.tokenType = "Bearer"
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		s.tokenType = token.TokenType
+	}
+	s.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		s.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if token.RefreshToken != "" && s.config.RefreshToken != "" {
+		s.refreshToken = token.RefreshToken // Rotated; client credentials never switch to the refresh grant
+	}
+	utils.LogDebug("OAuth2 token obtained, expires in %ds", token.ExpiresIn)
+	return nil
+}
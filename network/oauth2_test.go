@@ -0,0 +1,320 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iscrie/config"
+	"iscrie/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// tokenServer is a local OAuth2 token endpoint recording the requests it receives.
+type tokenServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  []tokenRequest
+	expiresIn int64
+	rotate    bool // Return a new refresh token with every access token
+}
+
+type tokenRequest struct {
+	grantType, refreshToken, clientID, clientSecret string
+}
+
+func newTokenServer(t *testing.T, expiresIn int64) *tokenServer {
+	t.Helper()
+	server := &tokenServer{expiresIn: expiresIn}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveToken))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *tokenServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	clientID, clientSecret, _ := r.BasicAuth()
+
+	s.mu.Lock()
+	s.requests = append(s.requests, tokenRequest{
+		grantType:    r.PostForm.Get("grant_type"),
+		refreshToken: r.PostForm.Get("refresh_token"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+	})
+	count := len(s.requests)
+	s.mu.Unlock()
+
+	response := map[string]any{
+		"access_token": fmt.Sprintf("token-%d", count),
+		"token_type":   "bearer",
+		"expires_in":   s.expiresIn,
+	}
+	if s.rotate {
+		response["refresh_token"] = fmt.Sprintf("refresh-%d", count)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *tokenServer) received() []tokenRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]tokenRequest{}, s.requests...)
+}
+
+func initTestLogger(t *testing.T) {
+	t.Helper()
+	if err := utils.InitLogger(t.TempDir(), utils.ErrorLevel); err != nil {
+		t.Fatalf("InitLogger: %v", err)
+	}
+	t.Cleanup(utils.CloseLogger)
+}
+
+func oauth2Config(tokenURL string) config.OAuth2Config {
+	return config.OAuth2Config{
+		TokenURL:      tokenURL,
+		ClientID:      "iscrie",
+		ClientSecret:  "client-secret",
+		AuthStyle:     "header",
+		RefreshBefore: 60,
+	}
+}
+
+func mustToken(t *testing.T, source *tokenSource) string {
+	t.Helper()
+	tokenType, token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if tokenType != "Bearer" {
+		t.Fatalf("token type = %q, want Bearer", tokenType)
+	}
+	return token
+}
+
+func TestTokenSourceClientCredentials(t *testing.T) {
+	initTestLogger(t)
+	server := newTokenServer(t, 3600)
+	server.rotate = true // A refresh token must not switch client credentials to the refresh grant
+	source := newTokenSource(oauth2Config(server.URL), server.Client())
+
+	if token := mustToken(t, source); token != "token-1" {
+		t.Fatalf("first token = %q, want token-1", token)
+	}
+	source.Invalidate("token-1")
+	if token := mustToken(t, source); token != "token-2" {
+		t.Fatalf("token after invalidation = %q, want token-2", token)
+	}
+
+	for i, request := range server.received() {
+		if request.grantType != "client_credentials" {
+			t.Errorf("request %d: grant_type = %q, want client_credentials", i, request.grantType)
+		}
+		if request.clientID != "iscrie" || request.clientSecret != "client-secret" {
+			t.Errorf("request %d: client credentials = %q:%q", i, request.clientID, request.clientSecret)
+		}
+	}
+}
+
+func TestTokenSourceCachesToken(t *testing.T) {
+	initTestLogger(t)
+	server := newTokenServer(t, 3600)
+	source := newTokenSource(oauth2Config(server.URL), server.Client())
+
+	for i := 0; i < 3; i++ {
+		if token := mustToken(t, source); token != "token-1" {
+			t.Fatalf("call %d: token = %q, want the cached token-1", i, token)
+		}
+	}
+	source.Invalidate("stale-token") // Another token: the cached one is kept
+	if token := mustToken(t, source); token != "token-1" {
+		t.Fatalf("token after invalidating another token = %q, want token-1", token)
+	}
+	if requests := len(server.received()); requests != 1 {
+		t.Fatalf("token requests = %d, want 1", requests)
+	}
+}
+
+func TestTokenSourceRenewsBeforeExpiry(t *testing.T) {
+	initTestLogger(t)
+	server := newTokenServer(t, 30) // Expires within refresh_before = 60s
+	source := newTokenSource(oauth2Config(server.URL), server.Client())
+
+	if token := mustToken(t, source); token != "token-1" {
+		t.Fatalf("first token = %q, want token-1", token)
+	}
+	if token := mustToken(t, source); token != "token-2" {
+		t.Fatalf("token expiring within refresh_before = %q, want the renewed token-2", token)
+	}
+
+	server.expiresIn = 120 // Expires after refresh_before
+	mustToken(t, source)
+	if token := mustToken(t, source); token != "token-3" {
+		t.Fatalf("token expiring after refresh_before = %q, want the cached token-3", token)
+	}
+}
+
+func TestTokenSourceRefreshTokenRotation(t *testing.T) {
+	initTestLogger(t)
+	server := newTokenServer(t, 30)
+	server.rotate = true
+	oauth2 := oauth2Config(server.URL)
+	oauth2.RefreshToken = "refresh-0"
+	source := newTokenSource(oauth2, server.Client())
+
+	for i := 0; i < 3; i++ {
+		mustToken(t, source)
+	}
+
+	requests := server.received()
+	if len(requests) != 3 {
+		t.Fatalf("token requests = %d, want 3", len(requests))
+	}
+	for i, request := range requests {
+		if request.grantType != "refresh_token" {
+			t.Errorf("request %d: grant_type = %q, want refresh_token", i, request.grantType)
+		}
+		// The first request uses the configured token, the next ones the token rotated by the previous response
+		if want := fmt.Sprintf("refresh-%d", i); request.refreshToken != want {
+			t.Errorf("request %d: refresh_token = %q, want %q", i, request.refreshToken, want)
+		}
+	}
+}
+
+// nexusServer answers 401 to the requests authorized with a rejected token and 201 to the others.
+type nexusServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	rejected map[string]bool // Bearer tokens answered 401
+	received []string        // "<token> <body>" of every request
+}
+
+func newNexusServer(t *testing.T, rejected ...string) *nexusServer {
+	t.Helper()
+	server := &nexusServer{rejected: map[string]bool{}}
+	for _, token := range rejected {
+		server.rejected[token] = true
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		server.mu.Lock()
+		server.received = append(server.received, token+" "+string(body))
+		reject := server.rejected[token]
+		server.mu.Unlock()
+		if reject {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *nexusServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.received...)
+}
+
+func newOAuth2Adapter(t *testing.T, tokenURL, nexusURL string) *HTTPClientAdapter {
+	t.Helper()
+	authenticator, err := NewAuthenticator(config.AuthConfig{Type: string(OAuth2Auth), OAuth2: oauth2Config(tokenURL)}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+	return NewHTTPClientAdapter(&HTTPClient{Client: http.DefaultClient, Authenticator: authenticator}, nexusURL, "repo", false)
+}
+
+func TestDoRetriesOnceWithRenewedToken(t *testing.T) {
+	initTestLogger(t)
+	tokens := newTokenServer(t, 3600)
+	nexus := newNexusServer(t, "token-1")
+	adapter := newOAuth2Adapter(t, tokens.URL, nexus.URL)
+
+	filePath := filepath.Join(t.TempDir(), "artifact.txt")
+	if err := os.WriteFile(filePath, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	req, _, err := adapter.CreateFileRequest(http.MethodPut, nexus.URL+"/repository/repo/artifact.txt", filePath)
+	if err != nil {
+		t.Fatalf("CreateFileRequest: %v", err)
+	}
+	resp, err := adapter.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	want := []string{"token-1 content", "token-2 content"}
+	if got := nexus.requests(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("requests = %q, want %q", got, want)
+	}
+	if requests := len(tokens.received()); requests != 2 {
+		t.Fatalf("token requests = %d, want 2", requests)
+	}
+}
+
+func TestDoRetriesOnlyOnce(t *testing.T) {
+	initTestLogger(t)
+	tokens := newTokenServer(t, 3600)
+	nexus := newNexusServer(t, "token-1", "token-2", "token-3")
+	adapter := newOAuth2Adapter(t, tokens.URL, nexus.URL)
+
+	req, err := http.NewRequest(http.MethodHead, nexus.URL+"/repository/repo/artifact.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := adapter.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want the 401 of the retry", resp.StatusCode)
+	}
+	if requests := len(nexus.requests()); requests != 2 {
+		t.Fatalf("requests to Nexus = %d, want 2", requests)
+	}
+}
+
+func TestDoDoesNotRetryStaticCredentials(t *testing.T) {
+	initTestLogger(t)
+	nexus := newNexusServer(t, "static-token")
+	authenticator, err := NewAuthenticator(config.AuthConfig{Type: string(BearerAuth), AccessToken: "static-token"}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+	adapter := NewHTTPClientAdapter(&HTTPClient{Client: http.DefaultClient, Authenticator: authenticator}, nexus.URL, "repo", false)
+
+	req, err := http.NewRequest(http.MethodGet, nexus.URL+"/repository/repo/artifact.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := adapter.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+
+	if requests := len(nexus.requests()); requests != 1 {
+		t.Fatalf("requests to Nexus = %d, want 1", requests)
+	}
+}
@@ -0,0 +1,261 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iscrie/config"
+	"iscrie/utils"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	terminalRefresh = 250 * time.Millisecond // Refresh period of the terminal display
+	maxShownFiles   = 8                      // Uploads listed by the terminal display
+	maxLineWidth    = 79                     // Terminal lines are truncated so that they do not wrap
+	rateSmoothing   = 0.3                    // Weight of the last period in the smoothed throughput
+)
+
+// Progress tracks the bytes sent by the uploads of a run, per file and overall, and reports them
+// on a live terminal display or in periodic log lines. It is safe for concurrent use.
+type Progress struct {
+	mu         sync.Mutex
+	start      time.Time
+	totalFiles int   // Zero if unknown
+	totalBytes int64 // Zero if unknown
+	doneFiles  int
+	doneBytes  int64 // Bytes of the finished files
+	active     map[string]*fileProgress
+
+	terminal   bool
+	interval   time.Duration
+	lastBytes  int64 // Bytes sent at the previous report
+	lastReport time.Time
+	rate       float64 // Smoothed throughput, in bytes per second
+	stop       chan struct{}
+	stopped    chan struct{}
+}
+
+// fileProgress is an upload in flight.
+type fileProgress struct {
+	name    string
+	size    int64
+	sent    int64
+	started time.Time
+}
+
+type progressKey struct{}
+
+// NewProgress creates the progress reporter of a mode, reporting in log lines every interval when
+// it does not use the terminal. It returns nil if the mode is off.
+func NewProgress(mode string, interval time.Duration) *Progress {
+	if mode == config.ProgressOff {
+		return nil
+	}
+	now := time.Now()
+	return &Progress{
+		start:      now,
+		active:     map[string]*fileProgress{},
+		terminal:   mode == config.ProgressAuto && utils.IsTerminal(),
+		interval:   interval,
+		lastReport: now,
+	}
+}
+
+// SetTotal sets the number of files and bytes expected in the run, used for percentages and the ETA.
+func (p *Progress) SetTotal(files int, bytes int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.totalFiles, p.totalBytes = files, bytes
+}
+
+// Start reports the progress until Stop is called.
+func (p *Progress) Start() {
+	if p == nil {
+		return
+	}
+	p.stop, p.stopped = make(chan struct{}), make(chan struct{})
+	period := p.interval
+	if p.terminal {
+		period = terminalRefresh
+	}
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the reporting, removes the terminal display and logs the final totals.
+func (p *Progress) Stop() {
+	if p == nil || p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+	if p.terminal {
+		utils.SetStatus(nil)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	elapsed := time.Since(p.start)
+	utils.LogInfo("Progress: %d file(s), %s processed in %s (%s/s on average)", p.doneFiles, utils.FormatBytes(p.doneBytes),
+		elapsed.Round(time.Second), utils.FormatBytes(int64(float64(p.doneBytes)/max(elapsed.Seconds(), 1))))
+}
+
+// StartFile records the upload of a file of the given size. The bodies of the requests sent under
+// the returned context are counted as the bytes of the file.
+func (p *Progress) StartFile(ctx context.Context, name string, size int64) context.Context {
+	if p == nil {
+		return ctx
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	file := &fileProgress{name: name, size: size, started: time.Now()}
+	p.active[name] = file
+	return context.WithValue(ctx, progressKey{}, file)
+}
+
+// FinishFile records the end of the upload of a file, whatever its outcome.
+func (p *Progress) FinishFile(name string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if file, ok := p.active[name]; ok {
+		delete(p.active, name)
+		p.doneFiles++
+		p.doneBytes += file.size
+	}
+}
+
+// Reader counts the bytes read from an upload body into the file of ctx, if any. A new body starts the
+// count of the file over, as it is a new attempt of the upload.
+func (p *Progress) Reader(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	file, ok := ctx.Value(progressKey{}).(*fileProgress)
+	if p == nil || body == nil || body == http.NoBody || !ok {
+		return body
+	}
+	p.mu.Lock()
+	file.sent = 0
+	p.mu.Unlock()
+	return &progressReader{ReadCloser: body, progress: p, file: file}
+}
+
+// progressReader is a request body whose bytes are counted by Progress.
+type progressReader struct {
+	io.ReadCloser
+	progress *Progress
+	file     *fileProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	if n > 0 {
+		r.progress.mu.Lock()
+		r.file.sent = min(r.file.sent+int64(n), r.file.size)
+		r.progress.mu.Unlock()
+	}
+	return n, err
+}
+
+// report refreshes the terminal display or logs a progress line.
+func (p *Progress) report() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	sent := p.doneBytes
+	files := make([]*fileProgress, 0, len(p.active))
+	for _, file := range p.active {
+		sent += file.sent
+		files = append(files, file)
+	}
+	if elapsed := now.Sub(p.lastReport).Seconds(); elapsed > 0 {
+		rate := float64(sent-p.lastBytes) / elapsed
+		if p.rate == 0 {
+			p.rate = rate
+		} else {
+			p.rate += rateSmoothing * (rate - p.rate)
+		}
+	}
+	p.lastBytes, p.lastReport = sent, now
+
+	summary := p.summaryLine(sent)
+	if !p.terminal {
+		utils.LogInfo("Progress: %s", summary)
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].started.Before(files[j].started) })
+	lines := []string{truncate("Progress: "+summary, maxLineWidth)}
+	for i, file := range files {
+		if i == maxShownFiles {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(files)-maxShownFiles))
+			break
+		}
+		lines = append(lines, fileLine(file))
+	}
+	utils.SetStatus(lines)
+}
+
+// summaryLine describes the overall progress: files, bytes, throughput and ETA. p.mu must be held.
+func (p *Progress) summaryLine(sent int64) string {
+	var line strings.Builder
+	if p.totalFiles > 0 {
+		fmt.Fprintf(&line, "files %d/%d", p.doneFiles, p.totalFiles)
+	} else {
+		fmt.Fprintf(&line, "files %d", p.doneFiles)
+	}
+	if p.totalBytes > 0 {
+		fmt.Fprintf(&line, ", %s/%s (%d%%)", utils.FormatBytes(sent), utils.FormatBytes(p.totalBytes), percent(sent, p.totalBytes))
+	} else {
+		fmt.Fprintf(&line, ", %s", utils.FormatBytes(sent))
+	}
+	fmt.Fprintf(&line, ", %s/s", utils.FormatBytes(int64(p.rate)))
+	if p.totalBytes > sent && p.rate > 0 {
+		eta := time.Duration(float64(p.totalBytes-sent) / p.rate * float64(time.Second))
+		fmt.Fprintf(&line, ", ETA %s", eta.Round(time.Second))
+	}
+	return line.String()
+}
+
+// fileLine describes the progress of an upload in flight.
+func fileLine(file *fileProgress) string {
+	counts := fmt.Sprintf(" %s/%s %3d%%", utils.FormatBytes(file.sent), utils.FormatBytes(file.size), percent(file.sent, file.size))
+	name := truncate(filepath.Base(file.name), maxLineWidth-len(counts)-2)
+	return "  " + name + counts
+}
+
+func percent(part, total int64) int64 {
+	if total <= 0 {
+		return 100
+	}
+	return part * 100 / total
+}
+
+// truncate shortens a line to width characters, ending it with "..." if it is cut.
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) <= width {
+		return line
+	}
+	return string(runes[:max(width-3, 0)]) + "..."
+}
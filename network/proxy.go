@@ -0,0 +1,263 @@
+package network
+
+import (
+	"fmt"
+	"iscrie/config"
+	"iscrie/utils"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Proxy selects the proxy of each request: the one of the [proxy] section or, if it is disabled,
+// the one of the HTTP_PROXY, HTTPS_PROXY and ALL_PROXY environment variables. Hosts matching
+// proxy.no_proxy or NO_PROXY are reached directly.
+type Proxy struct {
+	configured *url.URL // Proxy of the [proxy] section, nil if disabled
+	httpProxy  *url.URL // Environment proxies, by target scheme
+	httpsProxy *url.URL
+	noProxy    []noProxyRule
+	header     http.Header // Custom proxy authentication header
+}
+
+// noProxyRule is an entry of a no_proxy list.
+type noProxyRule struct {
+	all    bool       // "*"
+	ip     net.IP     // Exact address
+	cidr   *net.IPNet // Address range
+	domain string     // Domain and its subdomains, without leading dot
+	port   string     // Optional port restriction
+}
+
+// NewProxy builds the proxy selection from the [proxy] section and, if enabled, the environment.
+func NewProxy(proxyConfig config.ProxyConfig) (*Proxy, error) {
+	proxy := &Proxy{}
+
+	if proxyConfig.Enabled {
+		if proxyConfig.Host == "" || proxyConfig.Port == 0 {
+			return nil, fmt.Errorf("proxy is enabled but host or port is not defined")
+		}
+		scheme := proxyConfig.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		proxy.configured = &url.URL{
+			Scheme: scheme,
+			Host:   net.JoinHostPort(proxyConfig.Host, strconv.Itoa(proxyConfig.Port)),
+		}
+		if proxyConfig.Username != "" && proxyConfig.Password != "" {
+			proxy.configured.User = url.UserPassword(proxyConfig.Username, proxyConfig.Password)
+		}
+		if proxyConfig.HeaderName != "" {
+			proxy.header = http.Header{}
+			proxy.header.Set(proxyConfig.HeaderName, proxyConfig.HeaderValue)
+		}
+		utils.LogInfo("Proxy configured: %s", proxy.configured.Redacted())
+	} else if proxyConfig.UseEnvironment {
+		var err error
+		allProxy := getenv("ALL_PROXY")
+		if proxy.httpProxy, err = parseProxyURL(firstNonEmpty(getenv("HTTP_PROXY"), allProxy)); err != nil {
+			return nil, fmt.Errorf("invalid HTTP_PROXY: %w", err)
+		}
+		if proxy.httpsProxy, err = parseProxyURL(firstNonEmpty(getenv("HTTPS_PROXY"), allProxy)); err != nil {
+			return nil, fmt.Errorf("invalid HTTPS_PROXY: %w", err)
+		}
+		for _, environmentProxy := range []*url.URL{proxy.httpProxy, proxy.httpsProxy} {
+			if environmentProxy != nil {
+				utils.LogInfo("Proxy configured from the environment: %s", environmentProxy.Redacted())
+			}
+		}
+	}
+
+	entries := proxyConfig.NoProxy
+	if proxyConfig.UseEnvironment {
+		for _, entry := range strings.Split(getenv("NO_PROXY"), ",") {
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	}
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+		rule, err := parseNoProxyRule(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid no_proxy entry '%s': %w", entry, err)
+		}
+		proxy.noProxy = append(proxy.noProxy, rule)
+	}
+	return proxy, nil
+}
+
+// Configure sets the proxy of a transport. The custom authentication header is sent with
+// CONNECT requests; requests to http targets get it from WrapTransport.
+func (p *Proxy) Configure(transport *http.Transport) {
+	transport.Proxy = p.ProxyURL
+	if p.header != nil {
+		transport.ProxyConnectHeader = p.header.Clone()
+	}
+}
+
+// WrapTransport adds the custom authentication header to the requests sent in clear to an http proxy.
+func (p *Proxy) WrapTransport(transport http.RoundTripper) http.RoundTripper {
+	if p.header == nil {
+		return transport
+	}
+	return &proxyHeaderTransport{proxy: p, next: transport}
+}
+
+// ProxyURL returns the proxy of a request, or nil to connect directly. It is an http.Transport.Proxy function.
+func (p *Proxy) ProxyURL(req *http.Request) (*url.URL, error) {
+	proxyURL := p.configured
+	if proxyURL == nil {
+		if isLoopback(req.URL.Hostname()) {
+			return nil, nil // As with http.ProxyFromEnvironment
+		}
+		proxyURL = p.httpProxy
+		if req.URL.Scheme == "https" {
+			proxyURL = p.httpsProxy
+		}
+	}
+	if proxyURL == nil || p.bypass(req.URL) {
+		return nil, nil
+	}
+	return proxyURL, nil
+}
+
+// IsProxyHost reports whether a host name is the host of a proxy.
+func (p *Proxy) IsProxyHost(host string) bool {
+	for _, proxyURL := range []*url.URL{p.configured, p.httpProxy, p.httpsProxy} {
+		if proxyURL != nil && strings.EqualFold(proxyURL.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// bypass reports whether a target matches the no_proxy list.
+func (p *Proxy) bypass(target *url.URL) bool {
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	port := target.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[target.Scheme]
+	}
+	ip := net.ParseIP(host)
+
+	for _, rule := range p.noProxy {
+		if rule.port != "" && rule.port != port {
+			continue
+		}
+		switch {
+		case rule.all:
+			return true
+		case rule.cidr != nil:
+			if ip != nil && rule.cidr.Contains(ip) {
+				return true
+			}
+		case rule.ip != nil:
+			if ip != nil && rule.ip.Equal(ip) {
+				return true
+			}
+		case host == rule.domain || strings.HasSuffix(host, "."+rule.domain):
+			return true
+		}
+	}
+	return false
+}
+
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func parseNoProxyRule(entry string) (noProxyRule, error) {
+	rule := noProxyRule{}
+	if entry == "*" {
+		rule.all = true
+		return rule, nil
+	}
+	if strings.Contains(entry, "/") {
+		_, cidr, err := net.ParseCIDR(entry)
+		if err != nil {
+			return rule, err
+		}
+		rule.cidr = cidr
+		return rule, nil
+	}
+	if host, port, err := net.SplitHostPort(entry); err == nil {
+		entry, rule.port = host, port
+	}
+	if ip := net.ParseIP(entry); ip != nil {
+		rule.ip = ip
+		return rule, nil
+	}
+	rule.domain = strings.ToLower(strings.Trim(strings.TrimPrefix(entry, "*"), "."))
+	return rule, nil
+}
+
+// parseProxyURL parses the URL of an environment proxy, which defaults to the http scheme.
+func parseProxyURL(value string) (*url.URL, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	proxyURL, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("missing proxy host in %s", proxyURL.Redacted())
+	}
+	return proxyURL, nil
+}
+
+// getenv reads an environment variable by its upper or lower case name.
+func getenv(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return os.Getenv(strings.ToLower(name))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// proxyHeaderTransport adds the proxy authentication header to the requests to http targets that
+// go through an http proxy, which receives them as is.
+type proxyHeaderTransport struct {
+	proxy *Proxy
+	next  http.RoundTripper
+}
+
+func (t *proxyHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return t.next.RoundTrip(req)
+	}
+	proxyURL, err := t.proxy.ProxyURL(req)
+	if err != nil || proxyURL == nil || strings.HasPrefix(proxyURL.Scheme, "socks5") {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	for name, values := range t.proxy.header {
+		req.Header[name] = values
+	}
+	return t.next.RoundTrip(req)
+}
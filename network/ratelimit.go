@@ -0,0 +1,193 @@
+package network
+
+import (
+	"context"
+	"io"
+	"iscrie/config"
+	"iscrie/utils"
+	"sync"
+	"time"
+)
+
+// maxThrottledRead bounds the bytes read at once by a throttled body. Chunks are also kept below
+// 1/20 of the lowest bandwidth limit, so that the upload stays smooth and each wait is short.
+const maxThrottledRead = 32 * 1024
+
+// tokenBucket is a token bucket whose rate can change between calls. Tokens may go negative:
+// a caller takes what it needs and waits for the debt to be paid back.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens per second
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes n tokens at the given rate, per second, and returns the time to wait before using them.
+// The bucket holds at most one second of tokens, or one token for rates below one per second.
+func (b *tokenBucket) reserve(n, rate float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	burst := max(rate, 1)
+	if b.last.IsZero() || b.rate != rate {
+		b.tokens = min(b.tokens, burst)
+		if b.last.IsZero() {
+			b.tokens = burst
+		}
+	} else {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*rate, burst)
+	}
+	b.rate = rate
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// RateLimiter throttles the bandwidth and the request rate of uploads, globally and per host,
+// following the limits of [network] and its time-of-day schedules.
+type RateLimiter struct {
+	limits    config.RateLimits
+	schedules []limitWindow
+
+	mu            sync.Mutex
+	globalBytes   tokenBucket
+	globalRequest tokenBucket
+	hostBytes     map[string]*tokenBucket
+	hostRequests  map[string]*tokenBucket
+}
+
+// limitWindow is a parsed [[network.schedule]] entry.
+type limitWindow struct {
+	start, end time.Duration // Offsets from midnight
+	limits     config.RateLimits
+}
+
+// NewRateLimiter builds the limiter of the [network] section, or returns nil if it sets no limit.
+func NewRateLimiter(networkConfig config.NetworkConfig) (*RateLimiter, error) {
+	if networkConfig.RateLimits == (config.RateLimits{}) && len(networkConfig.Schedules) == 0 {
+		return nil, nil
+	}
+
+	limiter := &RateLimiter{
+		limits:       networkConfig.RateLimits,
+		hostBytes:    map[string]*tokenBucket{},
+		hostRequests: map[string]*tokenBucket{},
+	}
+	for _, schedule := range networkConfig.Schedules {
+		start, err := config.ParseTimeOfDay(schedule.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := config.ParseTimeOfDay(schedule.End)
+		if err != nil {
+			return nil, err
+		}
+		limiter.schedules = append(limiter.schedules, limitWindow{start: start, end: end, limits: schedule.RateLimits})
+	}
+	utils.LogInfo("Rate limits: %d bytes/s, %g requests/s, per host %d bytes/s, %g requests/s, %d schedule(s)",
+		limiter.limits.BytesPerSecond, limiter.limits.RequestsPerSecond,
+		limiter.limits.PerHostBytesPerSecond, limiter.limits.PerHostRequestsPerSecond, len(limiter.schedules))
+	return limiter, nil
+}
+
+// currentLimits returns the limits of the first schedule window containing the current time of day,
+// or the limits of [network].
+func (l *RateLimiter) currentLimits() config.RateLimits {
+	now := time.Now()
+	offset := now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	for _, window := range l.schedules {
+		inside := offset >= window.start && offset < window.end
+		if window.end < window.start {
+			inside = offset >= window.start || offset < window.end // Wraps around midnight
+		}
+		if inside {
+			return window.limits
+		}
+	}
+	return l.limits
+}
+
+// hostBucket returns the bucket of a host, creating it on first use.
+func (l *RateLimiter) hostBucket(buckets map[string]*tokenBucket, host string) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := buckets[host]
+	if !ok {
+		bucket = &tokenBucket{}
+		buckets[host] = bucket
+	}
+	return bucket
+}
+
+// take reserves n tokens from the global and host buckets whose rate is set, and returns the longest wait.
+func (l *RateLimiter) take(global *tokenBucket, hosts map[string]*tokenBucket, host string, n, globalRate, hostRate float64) time.Duration {
+	var wait time.Duration
+	if globalRate > 0 {
+		wait = global.reserve(n, globalRate)
+	}
+	if hostRate > 0 {
+		wait = max(wait, l.hostBucket(hosts, host).reserve(n, hostRate))
+	}
+	return wait
+}
+
+// WaitRequest blocks until a request to host is allowed by the request rate limits, or ctx is done.
+func (l *RateLimiter) WaitRequest(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+	limits := l.currentLimits()
+	wait := l.take(&l.globalRequest, l.hostRequests, host, 1, limits.RequestsPerSecond, limits.PerHostRequestsPerSecond)
+	if wait <= 0 {
+		return nil
+	}
+	utils.LogDebug("Request rate limit: waiting %s before sending to %s", wait, host)
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reader wraps the body of an upload to host so that it is read no faster than the bandwidth limits.
+func (l *RateLimiter) Reader(host string, body io.ReadCloser) io.ReadCloser {
+	if l == nil || body == nil {
+		return body
+	}
+	return &throttledReader{ReadCloser: body, limiter: l, host: host}
+}
+
+// throttledReader is a request body read at the pace of the bandwidth limits.
+type throttledReader struct {
+	io.ReadCloser
+	limiter *RateLimiter
+	host    string
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	limits := r.limiter.currentLimits()
+	if limits.BytesPerSecond == 0 && limits.PerHostBytesPerSecond == 0 {
+		return r.ReadCloser.Read(p)
+	}
+	chunk := int64(maxThrottledRead)
+	for _, rate := range []int64{limits.BytesPerSecond, limits.PerHostBytesPerSecond} {
+		if rate > 0 {
+			chunk = min(chunk, max(rate/20, 1))
+		}
+	}
+	if int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		time.Sleep(r.limiter.take(&r.limiter.globalBytes, r.limiter.hostBytes, r.host, float64(n),
+			float64(limits.BytesPerSecond), float64(limits.PerHostBytesPerSecond)))
+	}
+	return n, err
+}
@@ -0,0 +1,173 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"iscrie/config"
+	"iscrie/utils"
+	"os"
+	"sync"
+)
+
+// tlsVersions maps the values of tls.min_version to their crypto/tls constant.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsVerifier checks the certificates presented by Nexus and by the proxy.
+type tlsVerifier struct {
+	roots       *x509.CertPool
+	serverName  string                 // Name expected in every certificate but the proxy's, if set
+	isProxyHost func(host string) bool // Reports the hosts verified against their own name
+	pins        [][]byte               // Accepted SHA-256 hashes of SubjectPublicKeyInfo
+	insecure    bool                   // Chains and names are not verified
+	warned      sync.Map               // Hosts already reported as unverified
+}
+
+// NewTLSConfig builds the TLS settings shared by the Nexus and proxy connections from the [tls] section.
+// isProxyHost reports the proxy hosts, whose certificate is verified against their own name rather than
+// tls.server_name; it may be nil.
+func NewTLSConfig(tlsConfig config.TLSConfig, isProxyHost func(host string) bool) (*tls.Config, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if tlsConfig.MinVersion != "" {
+		version, ok := tlsVersions[tlsConfig.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version: %s", tlsConfig.MinVersion)
+		}
+		minVersion = version
+	}
+
+	verifier := &tlsVerifier{
+		serverName:  tlsConfig.ServerName,
+		isProxyHost: isProxyHost,
+		insecure:    tlsConfig.InsecureSkipVerify,
+	}
+	if verifier.isProxyHost == nil {
+		verifier.isProxyHost = func(string) bool { return false }
+	}
+	for _, pin := range tlsConfig.PinnedSPKI {
+		hash, err := config.ParseSPKIPin(pin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SPKI pin '%s': %w", pin, err)
+		}
+		verifier.pins = append(verifier.pins, hash)
+	}
+	if len(verifier.pins) > 0 && verifier.insecure {
+		return nil, errors.New("SPKI pins cannot be combined with insecure_skip_verify")
+	}
+
+	roots, err := loadCertPool(tlsConfig.CAFiles)
+	if err != nil {
+		return nil, err
+	}
+	verifier.roots = roots
+
+	result := &tls.Config{
+		RootCAs:          roots,
+		MinVersion:       minVersion,
+		VerifyConnection: verifier.verify,
+		// With a server name override, chains are verified by VerifyConnection so that it only applies to Nexus
+		InsecureSkipVerify: verifier.insecure || verifier.serverName != "",
+	}
+
+	if tlsConfig.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		result.Certificates = []tls.Certificate{certificate}
+		utils.LogDebug("TLS client certificate loaded from %s", tlsConfig.CertFile)
+	}
+
+	if verifier.insecure {
+		utils.LogError("WARNING: tls.insecure_skip_verify is enabled. Certificates of Nexus and of the proxy are NOT verified;")
+		utils.LogError("WARNING: credentials and uploads can be intercepted. Use tls.ca_files to trust an internal CA instead.")
+		if len(tlsConfig.CAFiles) > 0 || tlsConfig.ServerName != "" {
+			utils.LogError("WARNING: tls.ca_files and tls.server_name are ignored while tls.insecure_skip_verify is enabled.")
+		}
+	}
+	utils.LogDebug("TLS configured: min version %s, %d CA file(s), %d pin(s)", tlsConfig.MinVersion, len(tlsConfig.CAFiles), len(verifier.pins))
+	return result, nil
+}
+
+// loadCertPool returns the system roots with the certificates of the CA bundles added.
+func loadCertPool(caFiles []string) (*x509.CertPool, error) {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		utils.LogDebug("System certificate pool unavailable, using the configured CA files only: %v", err)
+		roots = x509.NewCertPool()
+	}
+	for _, caFile := range caFiles {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file '%s': %w", caFile, err)
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificate found in CA file '%s'", caFile)
+		}
+		utils.LogDebug("Trusted CA file: %s", caFile)
+	}
+	return roots, nil
+}
+
+// verify checks the certificate chain of a connection when crypto/tls does not, then its pins.
+// Pins are only matched against verified chains, never against the certificates the peer merely sent.
+func (v *tlsVerifier) verify(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("tls: server presented no certificate")
+	}
+
+	peer := state.ServerName
+	if peer == "" {
+		peer = "server " + state.PeerCertificates[0].Subject.String()
+	}
+
+	chains := state.VerifiedChains
+	switch {
+	case v.insecure:
+		if _, warned := v.warned.LoadOrStore(peer, true); !warned {
+			utils.LogError("WARNING: connected to %s without verifying its certificate (tls.insecure_skip_verify)", peer)
+		}
+	case v.serverName != "":
+		// ServerName is empty for IP addresses, which are then expected to be Nexus
+		dnsName := v.serverName
+		if state.ServerName != "" && v.isProxyHost(state.ServerName) {
+			dnsName = state.ServerName
+		}
+		intermediates := x509.NewCertPool()
+		for _, certificate := range state.PeerCertificates[1:] {
+			intermediates.AddCert(certificate)
+		}
+		var err error
+		chains, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         v.roots,
+			DNSName:       dnsName,
+			Intermediates: intermediates,
+		})
+		if err != nil {
+			return fmt.Errorf("tls: certificate of %s is not trusted: %w", peer, err)
+		}
+	}
+
+	if len(v.pins) == 0 {
+		return nil
+	}
+	for _, chain := range chains {
+		for _, certificate := range chain {
+			hash := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+			for _, pin := range v.pins {
+				if bytes.Equal(hash[:], pin) {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("tls: no certificate in the verified chain of %s matches tls.pinned_spki", peer)
+}
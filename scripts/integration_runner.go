@@ -1,8 +1,10 @@
 package scripts
 
 import (
+	"context"
 	"fmt"
 	"iscrie/config"
+	"iscrie/network"
 	"iscrie/utils"
 	"os"
 )
@@ -11,14 +13,14 @@ func RunIntegrationTest() {
 	// Charger la configuration pour récupérer le chemin des logs et le niveau
 	cfg, err := config.LoadConfig("./iscrie.toml")
 	if err != nil {
-		fmt.Printf("Failed to load config: %v\n", err)
+		fmt.Printf("Failed to load config: %s\n", utils.Redact(err.Error()))
 		os.Exit(1)
 	}
 
 	// Initialiser le logger
 	err = utils.InitLogger(cfg.General.LogPath, cfg.General.LogLevel)
 	if err != nil {
-		fmt.Printf("Failed to initialize logger: %v\n", err)
+		fmt.Printf("Failed to initialize logger: %s\n", utils.Redact(err.Error()))
 		os.Exit(1)
 	}
 	defer utils.CloseLogger()
@@ -56,7 +58,7 @@ func RunIntegrationTest() {
 		utils.LogInfo("Uploading files to Nexus...")
 
 		configPath := "./iscrie.toml"
-		err := UploadTestData(configPath)
+		err := UploadTestData(context.Background(), configPath)
 		if err != nil {
 			utils.LogError("Error uploading files to Nexus: %v", err)
 		} else {
@@ -66,19 +68,20 @@ func RunIntegrationTest() {
 	case 4:
 		utils.LogInfo("Validating Nexus upload...")
 
-		baseURL := "http://localhost:8081"
-		repoName := "test"
-		rootPath := "./test/testdata"
-
-		cfg, err := config.LoadConfig("./iscrie.toml")
+		httpClient, err := network.NewHTTPClient(cfg.Auth, cfg.Proxy, cfg.TLS)
 		if err != nil {
-			utils.LogError("Error loading configuration: %v", err)
+			utils.LogError("Failed to initialize HTTP client: %v", err)
 			return
 		}
 
-		err = ValidateNexusUpload(baseURL, repoName, rootPath, cfg)
+		report, err := ValidateNexusUpload(context.Background(), cfg, httpClient)
 		if err != nil {
 			utils.LogError("Error validating Nexus upload: %v", err)
+			return
+		}
+		report.LogSummary()
+		if report.Failed() {
+			utils.LogError("Nexus upload validation failed.")
 		} else {
 			utils.LogInfo("Nexus upload validation successful.")
 		}
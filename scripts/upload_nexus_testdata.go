@@ -2,6 +2,7 @@ package scripts
 
 import (
 	"bytes"
+	"context"
 	"iscrie/config"
 	"iscrie/core/importer/maven2"
 	"iscrie/core/importer/raw"
@@ -12,7 +13,8 @@ import (
 )
 
 // UploadTestData uploads the test data from the specified root directory to the Nexus repositories.
-func UploadTestData(configPath string) error {
+// The upload stops when ctx is done.
+func UploadTestData(ctx context.Context, configPath string) error {
 	// Load configuration from TOML file.
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
@@ -28,7 +30,7 @@ func UploadTestData(configPath string) error {
 	utils.LogInfo("Starting upload of test data from %s...", rootPath)
 
 	// Initialize HTTP client
-	httpClient, err := network.NewHTTPClient(cfg.Auth, cfg.Proxy)
+	httpClient, err := network.NewHTTPClient(cfg.Auth, cfg.Proxy, cfg.TLS)
 	if err != nil {
 		return utils.LogAndReturnError("Failed to initialize HTTP client: %w", err)
 	}
@@ -57,6 +59,7 @@ func UploadTestData(configPath string) error {
 		if filepath.Ext(path) == ".pom" || filepath.Ext(path) == ".jar" || filepath.Ext(path) == ".war" || filepath.Ext(path) == ".ear" {
 			utils.LogDebug("Attempting to upload Maven2 artifact: %s", path)
 			uploadErr = maven2Importer.UploadMaven2File(
+				ctx,
 				path,
 				cfg.Retry.RetryAttempts,
 				utils.LogDebug,
@@ -65,6 +68,7 @@ func UploadTestData(configPath string) error {
 		} else {
 			utils.LogDebug("Attempting to upload RAW file: %s", path)
 			uploadErr = rawImporter.UploadRawFile(
+				ctx,
 				path,
 				cfg.Retry.RetryAttempts,
 				utils.LogDebug,
@@ -79,7 +83,7 @@ func UploadTestData(configPath string) error {
 			utils.LogInfo("Successfully uploaded: %s", path)
 		}
 
-		return nil
+		return ctx.Err()
 	})
 
 	// Check for errors during file walk
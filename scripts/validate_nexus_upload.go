@@ -1,104 +1,356 @@
 package scripts
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"iscrie/config"
-	"iscrie/core/importer/maven2"
+	"iscrie/core/importer"
 	"iscrie/network"
 	"iscrie/utils"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
-// ValidateNexusUpload vérifie que tous les fichiers du répertoire local existent dans le dépôt Nexus.
-func ValidateNexusUpload(baseURL, repoName, rootPath string, cfg *config.Config) error {
-	utils.LogInfo("Starting validation of files in %s against Nexus repository %s...", rootPath, repoName)
+// Statuses of the files and assets in a validation report.
+const (
+	StatusOK           = "ok"
+	StatusMissing      = "missing"      // The file is not in the repository
+	StatusMismatched   = "mismatched"   // The asset content differs from the file
+	StatusExtra        = "extra"        // The asset has no file
+	StatusUnverifiable = "unverifiable" // The target of the file cannot be computed
+)
+
+// AssetReport is the outcome of a file, or of an extra asset of the repository.
+type AssetReport struct {
+	File       string `json:"file,omitempty"`
+	Repository string `json:"repository"`
+	Path       string `json:"path,omitempty"`
+	Status     string `json:"status"`
+	LocalSHA1  string `json:"local_sha1,omitempty"`
+	RemoteSHA1 string `json:"remote_sha1,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
 
-	// Créer une instance du HTTPClient
-	httpClient, err := network.NewHTTPClient(cfg.Auth, cfg.Proxy)
+// ValidationReport lists the files that are not in Nexus as they are on disk, and the assets without a file.
+type ValidationReport struct {
+	Summary      map[string]int `json:"summary"` // Count per status
+	Missing      []AssetReport  `json:"missing"`
+	Mismatched   []AssetReport  `json:"mismatched"`
+	Extra        []AssetReport  `json:"extra"`
+	Unverifiable []AssetReport  `json:"unverifiable"`
+}
+
+// Failed reports whether a file is missing or differs from its asset.
+func (r *ValidationReport) Failed() bool {
+	return len(r.Missing) > 0 || len(r.Mismatched) > 0
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *ValidationReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// LogSummary logs the counts of the report and every file or asset that is not ok.
+func (r *ValidationReport) LogSummary() {
+	utils.LogInfo("Validation summary:")
+	for _, status := range []string{StatusOK, StatusMissing, StatusMismatched, StatusExtra, StatusUnverifiable} {
+		utils.LogInfo("  %s: %d", status, r.Summary[status])
+	}
+	for _, asset := range r.Missing {
+		utils.LogError("Missing in '%s': %s (%s)", asset.Repository, asset.Path, asset.File)
+	}
+	for _, asset := range r.Mismatched {
+		utils.LogError("Mismatched in '%s': %s (local sha1 %s, remote sha1 %s)", asset.Repository, asset.Path, asset.LocalSHA1, asset.RemoteSHA1)
+	}
+	for _, asset := range r.Extra {
+		utils.LogInfo("Extra in '%s': %s", asset.Repository, asset.Path)
+	}
+	for _, asset := range r.Unverifiable {
+		utils.LogError("Unverifiable: %s (%s)", asset.File, asset.Error)
+	}
+}
+
+// validation holds the state of ValidateNexusUpload.
+type validation struct {
+	router      *importer.Router
+	filter      *importer.Filter
+	nexusClient *network.NexusClient
+	adapter     *network.HTTPClientAdapter
+	assets      map[string]map[string]network.Asset // Assets of each repository, by path
+	expected    map[string]map[string]bool          // Paths of the local files, by repository
+	report      *ValidationReport
+}
+
+// ValidateNexusUpload checks that every file of root_path is stored in Nexus with the same content.
+// Files are filtered and routed as for an upload, and located with the URL builder of their repository type;
+// their SHA-1 is compared with the checksum reported by the assets API, or with the downloaded asset.
+// Assets of the routed repositories that match no file are reported as extra. The walk stops when ctx is done.
+func ValidateNexusUpload(ctx context.Context, cfg *config.Config, httpClient *network.HTTPClient) (*ValidationReport, error) {
+	utils.LogInfo("Starting validation of files in %s against Nexus %s...", cfg.General.RootPath, cfg.Nexus.URL)
+
+	router, err := importer.NewRouter(cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := importer.NewFilter(cfg)
 	if err != nil {
-		return utils.LogAndReturnError("Failed to initialize HTTP client: %w", err)
+		return nil, err
+	}
+	adapter := network.NewHTTPClientAdapter(httpClient, cfg.Nexus.URL, cfg.Nexus.Repository, false)
+	nexusClient, err := network.NewNexusClient(cfg.Nexus.URL, adapter)
+	if err != nil {
+		return nil, err
 	}
 
-	// Créer un adaptateur HTTPClientAdapter pour gérer les requêtes
-	httpClientAdapter := network.NewHTTPClientAdapter(httpClient, baseURL, repoName, cfg.Nexus.ForceReplace)
+	v := &validation{
+		router:      router,
+		filter:      filter,
+		nexusClient: nexusClient,
+		adapter:     adapter,
+		assets:      map[string]map[string]network.Asset{},
+		expected:    map[string]map[string]bool{},
+		report: &ValidationReport{
+			Summary:      map[string]int{},
+			Missing:      []AssetReport{},
+			Mismatched:   []AssetReport{},
+			Extra:        []AssetReport{},
+			Unverifiable: []AssetReport{},
+		},
+	}
+
+	// Step 1: Check every local file
+	if importer.IsArchive(cfg.General.RootPath) {
+		err = importer.WalkArchive(cfg.General.RootPath, func(entry *importer.ArchiveEntry) error {
+			v.validateFile(ctx, entry.Path, entry.Info, entry.Open, true)
+			return ctx.Err()
+		})
+	} else {
+		err = filepath.WalkDir(cfg.General.RootPath, func(filePath string, d os.DirEntry, err error) error {
+			if err != nil {
+				utils.LogError("Error accessing path %s: %v", filePath, err)
+				return nil
+			}
+			if d.IsDir() {
+				if filter.SkipDir(filePath) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				utils.LogError("Error reading file info %s: %v", filePath, err)
+				return nil
+			}
+			v.validateFile(ctx, filePath, info, func() (io.ReadCloser, error) { return os.Open(filePath) }, false)
+			return ctx.Err()
+		})
+	}
+	if err != nil {
+		return nil, utils.LogAndReturnError("Error traversing files: %w", err)
+	}
 
-	// Créer une instance de Maven2Importer
-	mavenImporter := maven2.NewMaven2Importer(baseURL, repoName, rootPath, httpClient, cfg.Nexus.ForceReplace)
+	// Step 2: Report the assets matching no file
+	v.collectExtraAssets()
+	return v.report, nil
+}
 
-	// Résultats de validation
-	var missingFiles []string
-	totalFiles := 0
-	validatedFiles := 0
+// validateFile locates a file in its repository and compares its content with the asset. Archive entries
+// are only located for the formats that upload from archives, as the others read the file from disk.
+func (v *validation) validateFile(ctx context.Context, filePath string, info os.FileInfo, open func() (io.ReadCloser, error), archiveEntry bool) {
+	if v.filter.Skip(filePath, info) != "" {
+		return
+	}
+	route, err := v.router.Route(filePath)
+	if err != nil || !route.Format.Matches(filePath) {
+		return
+	}
 
-	// Parcourir les fichiers locaux
-	err = filepath.WalkDir(rootPath, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			utils.LogError("Error accessing path %s: %v", path, err)
-			return nil // Continuer avec les autres fichiers
-		}
-		if d.IsDir() {
-			return nil // Ignorer les répertoires
-		}
+	result := AssetReport{File: filePath, Repository: route.Repository}
+	if route.Locate == nil {
+		result.Error = fmt.Sprintf("repository type '%s' does not support verification", route.Format.RepositoryType)
+		v.add(result, StatusUnverifiable)
+		return
+	}
+	if archiveEntry && route.UploadEntry == nil {
+		result.Error = fmt.Sprintf("repository type '%s' cannot be verified from an archive", route.Format.RepositoryType)
+		v.add(result, StatusUnverifiable)
+		return
+	}
+	targetURL, err := route.Locate(filePath)
+	if err != nil {
+		result.Error = err.Error()
+		v.add(result, StatusUnverifiable)
+		return
+	}
+	result.Path = network.RepositoryPath(targetURL, route.Repository)
+	utils.LogDebug("Checking %s -> %s", filePath, targetURL)
 
-		// Incrémenter le compteur total des fichiers
-		totalFiles++
+	assets, err := v.repositoryAssets(ctx, route.Repository)
+	if err != nil {
+		result.Error = err.Error()
+		v.add(result, StatusUnverifiable)
+		return
+	}
+	v.expected[route.Repository][result.Path] = true
+
+	asset, ok := assets[result.Path]
+	if !ok {
+		v.add(result, StatusMissing)
+		return
+	}
 
-		// Construire l'URL complète en utilisant Maven2Importer
-		fullURL, err := mavenImporter.BuildFullTargetURL(path)
+	if result.LocalSHA1, err = hashContent(open); err != nil {
+		result.Error = fmt.Sprintf("failed to hash file: %v", err)
+		v.add(result, StatusUnverifiable)
+		return
+	}
+	if result.RemoteSHA1, err = v.remoteSHA1(ctx, asset); err != nil {
+		result.Error = err.Error()
+		v.add(result, StatusUnverifiable)
+		return
+	}
+	if !strings.EqualFold(result.LocalSHA1, result.RemoteSHA1) {
+		v.add(result, StatusMismatched)
+		return
+	}
+	v.add(result, StatusOK)
+}
+
+func (v *validation) add(result AssetReport, status string) {
+	result.Status = status
+	v.report.Summary[status]++
+	switch status {
+	case StatusMissing:
+		v.report.Missing = append(v.report.Missing, result)
+	case StatusMismatched:
+		v.report.Mismatched = append(v.report.Mismatched, result)
+	case StatusExtra:
+		v.report.Extra = append(v.report.Extra, result)
+	case StatusUnverifiable:
+		v.report.Unverifiable = append(v.report.Unverifiable, result)
+	}
+}
+
+// repositoryAssets lists the assets of a repository once.
+func (v *validation) repositoryAssets(ctx context.Context, repository string) (map[string]network.Asset, error) {
+	if assets, ok := v.assets[repository]; ok {
+		return assets, nil
+	}
+	list, err := v.nexusClient.ListAssets(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	assets := make(map[string]network.Asset, len(list))
+	for _, asset := range list {
+		assets[asset.Path] = asset
+	}
+	v.assets[repository] = assets
+	v.expected[repository] = map[string]bool{}
+	utils.LogDebug("Listed %d assets in repository '%s'", len(assets), repository)
+	return assets, nil
+}
+
+// remoteSHA1 returns the SHA-1 reported by Nexus, or hashes the downloaded asset if it has none.
+func (v *validation) remoteSHA1(ctx context.Context, asset network.Asset) (string, error) {
+	if sha1Sum := asset.Checksum["sha1"]; sha1Sum != "" {
+		return sha1Sum, nil
+	}
+	return hashContent(func() (io.ReadCloser, error) {
+		req, err := http.NewRequest(http.MethodGet, asset.DownloadURL, nil)
 		if err != nil {
-			utils.LogError("Error generating URL for %s: %v", path, err)
-			missingFiles = append(missingFiles, path)
-			return nil
+			return nil, err
 		}
-		utils.LogDebug("Checking URL: %s", fullURL)
-
-		// Vérifier l'existence du fichier dans Nexus
-		req, err := http.NewRequest(http.MethodHead, fullURL, nil)
+		resp, err := v.adapter.Do(ctx, req)
 		if err != nil {
-			utils.LogError("Error creating HTTP request for %s: %v", fullURL, err)
-			missingFiles = append(missingFiles, path)
-			return nil
+			return nil, fmt.Errorf("failed to download %s: %w", asset.DownloadURL, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected response status %d when downloading %s", resp.StatusCode, asset.DownloadURL)
 		}
+		return resp.Body, nil
+	})
+}
 
-		resp, err := httpClientAdapter.Do(req)
-		if err != nil || resp.StatusCode != http.StatusOK {
-			statusCode := 0
-			if resp != nil {
-				statusCode = resp.StatusCode
-			}
-			utils.LogError("Missing file in Nexus: %s (HTTP %d)", path, statusCode)
-			missingFiles = append(missingFiles, path)
-			return nil
+// collectExtraAssets reports the assets that match no file. Signatures and checksums of
+// matched files and Maven metadata, which Nexus generates, are not extra.
+func (v *validation) collectExtraAssets() {
+	for repository, assets := range v.assets {
+		prefixes := v.targetPrefixes(repository)
+		paths := make([]string, 0, len(assets))
+		for assetPath := range assets {
+			paths = append(paths, assetPath)
 		}
-		defer resp.Body.Close()
+		sort.Strings(paths)
 
-		// Si le fichier est validé, incrémenter le compteur
-		validatedFiles++
-		utils.LogDebug("Validated file: %s", path)
-		return nil
+		for _, assetPath := range paths {
+			if v.expected[repository][assetPath] || !hasAnyPrefix(assetPath, prefixes) {
+				continue
+			}
+			if strings.HasPrefix(path.Base(assetPath), "maven-metadata.xml") {
+				continue
+			}
+			artifactPath := assetPath
+			for importer.IsSidecarFile(artifactPath) {
+				artifactPath = strings.TrimSuffix(artifactPath, path.Ext(artifactPath))
+			}
+			if artifactPath != assetPath && v.expected[repository][artifactPath] {
+				continue
+			}
+			v.add(AssetReport{Repository: repository, Path: assetPath, RemoteSHA1: assets[assetPath].Checksum["sha1"]}, StatusExtra)
+		}
+	}
+	sort.Slice(v.report.Extra, func(i, j int) bool {
+		if v.report.Extra[i].Repository != v.report.Extra[j].Repository {
+			return v.report.Extra[i].Repository < v.report.Extra[j].Repository
+		}
+		return v.report.Extra[i].Path < v.report.Extra[j].Path
 	})
+}
 
-	// Vérification des erreurs lors de la traversée des fichiers
-	if err != nil {
-		utils.LogError("Error traversing files: %v", err)
-		return err
+// targetPrefixes returns the target prefixes of the routes to a repository; files outside of them are not ours.
+func (v *validation) targetPrefixes(repository string) []string {
+	var prefixes []string
+	for _, route := range v.router.Routes {
+		if route.Repository != repository {
+			continue
+		}
+		if route.TargetPrefix == "" {
+			return []string{""}
+		}
+		prefixes = append(prefixes, route.TargetPrefix+"/")
 	}
+	return prefixes
+}
 
-	// Résumé de validation
-	utils.LogInfo("\nValidation summary:")
-	utils.LogInfo("Total files processed: %d", totalFiles)
-	utils.LogInfo("Total files validated: %d", validatedFiles)
-
-	if len(missingFiles) > 0 {
-		utils.LogError("Total missing files: %d", len(missingFiles))
-		utils.LogError("\nList of missing files:")
-		for _, file := range missingFiles {
-			utils.LogError("- %s", file)
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
 		}
-		return utils.LogAndReturnError("Validation completed with missing files")
 	}
+	return false
+}
 
-	utils.LogInfo("All files validated successfully in Nexus.")
-	return nil
+func hashContent(open func() (io.ReadCloser, error)) (string, error) {
+	content, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
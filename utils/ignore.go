@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// IgnorePattern is one gitignore-style pattern.
+type IgnorePattern struct {
+	Pattern string
+	Negate  bool // "!pattern" re-includes what a previous pattern excluded
+	DirOnly bool // "pattern/" only matches directories
+	re      *regexp.Regexp
+}
+
+// ParseIgnorePattern parses a gitignore-style pattern. Patterns without a slash match
+// a name at any depth; patterns containing a slash are anchored to the root.
+// It returns nil for blank lines and comments.
+func ParseIgnorePattern(line string) (*IgnorePattern, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	pattern := &IgnorePattern{Pattern: line}
+	if strings.HasPrefix(line, "!") {
+		pattern.Negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		pattern.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+
+	re, err := CompileGlob(line)
+	if err != nil {
+		return nil, err
+	}
+	pattern.re = re
+	return pattern, nil
+}
+
+// Match reports whether a slash-separated path relative to the root matches the pattern.
+func (p *IgnorePattern) Match(relativePath string, isDir bool) bool {
+	if p.DirOnly && !isDir {
+		return false
+	}
+	return p.re.MatchString(relativePath)
+}
+
+// MatchIgnorePatterns applies patterns in order, the last matching one wins, and reports
+// whether the path is matched. A path is also matched when one of its parent directories is.
+func MatchIgnorePatterns(patterns []*IgnorePattern, relativePath string, isDir bool) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if pattern.Match(relativePath, isDir) || matchParent(pattern, relativePath) {
+			matched = !pattern.Negate
+		}
+	}
+	return matched
+}
+
+func matchParent(pattern *IgnorePattern, relativePath string) bool {
+	for dir := path.Dir(relativePath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if pattern.Match(dir, true) {
+			return true
+		}
+	}
+	return false
+}
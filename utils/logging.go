@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -20,8 +22,59 @@ var (
 	logFile  *os.File
 	logLevel string
 	logger   *log.Logger
+	console  = &statusWriter{out: os.Stdout}
 )
 
+// statusWriter writes the log to stdout, keeping status lines, such as the progress display,
+// drawn below the last log line.
+type statusWriter struct {
+	mu     sync.Mutex
+	out    *os.File
+	status []string
+	drawn  int // Status lines currently on screen
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.erase()
+	n, err := w.out.Write(p)
+	w.draw()
+	return n, err
+}
+
+// erase removes the status lines from the screen. w.mu must be held.
+func (w *statusWriter) erase() {
+	if w.drawn > 0 {
+		fmt.Fprintf(w.out, "\x1b[%dA\x1b[J", w.drawn)
+		w.drawn = 0
+	}
+}
+
+// draw writes the status lines below the cursor. w.mu must be held.
+func (w *statusWriter) draw() {
+	if len(w.status) > 0 {
+		fmt.Fprint(w.out, strings.Join(w.status, "\n")+"\n")
+		w.drawn = len(w.status)
+	}
+}
+
+// IsTerminal reports whether stdout is a terminal.
+func IsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// SetStatus replaces the status lines shown on the terminal below the log; nil removes them.
+// They are not written to the log file.
+func SetStatus(lines []string) {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	console.erase()
+	console.status = lines
+	console.draw()
+}
+
 // InitLogger initializes the logging system
 func InitLogger(logPath string, level string) error {
 	// Ensure the logs directory exists
@@ -40,9 +93,9 @@ func InitLogger(logPath string, level string) error {
 	// Set log level
 	logLevel = level
 
-	// Create a multi-writer to log both to file and stdout
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-	logger = log.New(multiWriter, "", log.Ldate|log.Ltime)
+	// Create a multi-writer to log both to file and stdout, masking secrets in every line
+	multiWriter := io.MultiWriter(console, logFile)
+	logger = log.New(NewRedactingWriter(multiWriter), "", log.Ldate|log.Ltime)
 
 	// Log initialization
 	logger.Println("[INFO] Logger initialized successfully.")
@@ -75,8 +128,9 @@ func LogError(format string, v ...interface{}) {
 	logger.Printf("[ERROR] "+format, v...)
 }
 
-// LogAndReturnError logs an error message and returns an error formatted with fmt.Errorf
+// LogAndReturnError logs an error message and returns an error formatted with fmt.Errorf,
+// whose message has its secrets masked
 func LogAndReturnError(format string, v ...interface{}) error {
-	LogError(format, v...)          // Log the error
-	return fmt.Errorf(format, v...) // Return the error message
+	LogError(format, v...)                               // Log the error
+	return &redactedError{err: fmt.Errorf(format, v...)} // Return the error message
 }
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"encoding/base64"
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the secrets masked in log lines and error messages.
+const Redacted = "[REDACTED]"
+
+// minSecretLength is the length below which a registered value is not masked, as it would match
+// too many ordinary words. Such values are still masked in secret headers and URL passwords.
+const minSecretLength = 4
+
+var (
+	redactMu      sync.RWMutex
+	secrets       []string // Longest first, so that a secret containing another is masked whole
+	secretHeaders = map[string]bool{"authorization": true, "proxy-authorization": true}
+	headerPattern *regexp.Regexp
+)
+
+var (
+	// userinfoPattern matches the password of the userinfo of a URL.
+	userinfoPattern = regexp.MustCompile(`(\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]*):[^/\s@]*@`)
+
+	// apiKeyPattern matches the values of headers that commonly carry API keys.
+	apiKeyPattern = regexp.MustCompile(`(?i)(\b(?:x-)?api[-_]?key|\bx-auth-token|\bprivate-token)(["']?\s*[:=]\s*["'\[]?)[^\s"'&,;\[\]]+`)
+
+	// tokenParamPattern matches the tokens and passwords of form parameters and JSON fields,
+	// such as those of OAuth2 token requests and responses.
+	tokenParamPattern = regexp.MustCompile(`(?i)(\b(?:access_token|refresh_token|client_secret|password)(?:=|["']\s*:\s*["']))[^\s"'&,;\[\]]+`)
+)
+
+func init() {
+	headerPattern = compileHeaderPattern()
+}
+
+// RegisterSecret makes the given values masked in every log line and in the errors returned by
+// LogAndReturnError, along with their URL-escaped and base64 forms. Values shorter than
+// minSecretLength are ignored.
+func RegisterSecret(values ...string) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	for _, value := range values {
+		if len(value) < minSecretLength {
+			continue
+		}
+		forms := []string{value, url.QueryEscape(value), url.PathEscape(value), base64.StdEncoding.EncodeToString([]byte(value))}
+		for _, form := range forms {
+			if !slices.Contains(secrets, form) {
+				secrets = append(secrets, form)
+			}
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+// RegisterSecretHeader makes the values of a header masked wherever "Name: value" or "Name=value" is logged.
+// Authorization and Proxy-Authorization are always masked.
+func RegisterSecretHeader(names ...string) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	for _, name := range names {
+		if name != "" {
+			secretHeaders[strings.ToLower(name)] = true
+		}
+	}
+	headerPattern = compileHeaderPattern()
+}
+
+// compileHeaderPattern matches the values of the secret headers, keeping an authentication scheme
+// such as Basic or Bearer. redactMu must be held, except in init.
+func compileHeaderPattern() *regexp.Regexp {
+	names := make([]string, 0, len(secretHeaders))
+	for name := range secretHeaders {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	sort.Strings(names)
+	return regexp.MustCompile(`(?i)(\b(?:` + strings.Join(names, "|") + `)["']?\s*[:=]\s*["'\[]?(?:(?:basic|bearer|token|digest)\s+)?)[^\s"',;\[\]]+`)
+}
+
+// Redact masks the registered secrets, the values of secret headers, API keys and the passwords of URLs in a text.
+func Redact(text string) string {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+	text = headerPattern.ReplaceAllString(text, "${1}"+Redacted)
+	text = apiKeyPattern.ReplaceAllString(text, "${1}${2}"+Redacted)
+	text = tokenParamPattern.ReplaceAllString(text, "${1}"+Redacted)
+	text = userinfoPattern.ReplaceAllString(text, "${1}:"+Redacted+"@")
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, Redacted)
+	}
+	return text
+}
+
+// redactingWriter masks secrets in each write. The log package writes each line with a single call.
+type redactingWriter struct {
+	out io.Writer
+}
+
+// NewRedactingWriter returns a writer masking secrets with Redact before writing to out.
+func NewRedactingWriter(out io.Writer) io.Writer {
+	return &redactingWriter{out: out}
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redactedError is an error whose message is masked with Redact. The wrapped errors are kept for errors.Is and errors.As.
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}